@@ -2,114 +2,1015 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type entry struct {
-	value any
-	time  time.Time
+type entry[V any] struct {
+	value   V
+	size    int64  // as reported by Cache.sizer, zero without one
+	weight  int    // as given to SetWithWeight, otherwise 1
+	packed  []byte // compressed value, see WithCompression; value is zero
+	ttl     time.Duration
+	expires time.Time
+	index   int // position in Cache.expiries, -1 when not scheduled
+	tags    []string
+
+	negative bool // records a failed load, see WithNegativeTTL; value is zero
+}
+
+type item[K comparable, V any] struct {
+	key     K
+	value   V
+	expired bool // removed by TTL expiry rather than eviction
 }
 
-type Cache struct {
-	store  map[string]entry
-	size   int
+type Cache[K comparable, V any] struct {
+	store  map[K]*entry[V]
+	policy EvictionPolicy[K]
+	clock  Clock
+	size   int // maximum entry count, zero when bounded by maxBytes
 	ttl    time.Duration
+
+	maxBytes      int64 // maximum total of entry sizes, zero when bounded by size
+	bytes         int64
+	sizer         Sizer[V]
+	maxValueBytes int64 // maximum size of a single entry, zero for no limit
+
+	maxWeight int          // maximum total of entry weights, zero for no limit
+	weight    atomic.Int64 // total of entry weights, written with mu held
+
 	mu     sync.RWMutex
 	cancel context.CancelFunc
+
+	onEvict     func(key K, value V)
+	onExpire    func(key K, value V) // set only at construction
+	cloner      func(value V) V      // see WithCopyOnGet
+	counters    counters
+	loads       loadGroup[K, V]
+	staleWindow time.Duration
+	loader      func(key K) (V, error)
+	events      chan Event[K, V]
+	tags        map[string]map[K]struct{} // tag to the keys carrying it
+	expiries    expiryHeap[K, V]
+	jitter      float64        // fraction of the TTL to randomly spread expiries by
+	rand        func() float64 // in [0, 1), used for jitter
+	persistPath string
+	negativeTTL time.Duration
+	waiters     map[K]*waiter
+	evictBatch  int           // entries evicted at once when the cache is full
+	wake        chan struct{} // signals ttlEnforcer that the next expiry moved
+	lazy        bool          // no ttlEnforcer, see WithLazyExpiry
+	logf        func(format string, args ...any)
+	subs        map[K]map[chan V]struct{} // see Subscribe
+
+	codec         Codec[V] // nil unless values are compressed
+	compressAbove int      // encoded size over which values are compressed
+}
+
+// Sizer reports the size in bytes of a cached value.
+type Sizer[V any] func(value V) int64
+
+// ErrValueTooLarge is returned by TrySet for a value over the size limit.
+var ErrValueTooLarge = errors.New("value too large")
+
+// New creates a cache holding at most size entries, each expiring ttl after
+// it was written. It is shorthand for NewWithOptions with WithSize and
+// WithTTL followed by opts.
+func New[K comparable, V any](size int, ttl time.Duration, opts ...Option) (*Cache[K, V], error) {
+	return NewWithOptions[K, V](append([]Option{WithSize(size), WithTTL(ttl)}, opts...)...)
+}
+
+// NewWithBytes creates a cache bounded by the total size of its values, as
+// measured by sizer, rather than by entry count. Set evicts entries until
+// the new value fits; a single value larger than maxBytes is not stored, and
+// evicts the value its key held before.
+func NewWithBytes[K comparable, V any](maxBytes int64, ttl time.Duration, sizer Sizer[V], opts ...Option) (*Cache[K, V], error) {
+
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("maxBytes should be greater than zero")
+	}
+
+	if sizer == nil {
+		return nil, fmt.Errorf("sizer is required")
+	}
+
+	return NewWithOptions[K, V](append([]Option{WithTTL(ttl), withBytes(maxBytes, sizer)}, opts...)...)
 }
 
-func New(size int, ttl time.Duration) (*Cache, error) {
+// NewWithOptions creates a cache configured by opts. Without WithSize or
+// WithTTL the cache holds 1024 entries that expire after a minute.
+func NewWithOptions[K comparable, V any](opts ...Option) (*Cache[K, V], error) {
+	o := options{size: defaultSize, ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	if size <= 0 {
+	if o.maxBytes > 0 {
+		o.size = 0
+	} else if o.size <= 0 {
 		return nil, fmt.Errorf("size should be greater than zero")
 	}
 
-	if ttl <= 0 {
+	if o.ttl <= 0 {
 		return nil, fmt.Errorf("ttl should be greater than zero")
 	}
 
+	storage := make(map[K]*entry[V])
+
+	cache := &Cache[K, V]{
+		store:    storage,
+		policy:   NewLRU[K](),
+		clock:    realClock{},
+		size:     o.size,
+		ttl:      o.ttl,
+		maxBytes: o.maxBytes,
+		wake:     make(chan struct{}, 1),
+	}
+	if err := cache.apply(o); err != nil {
+		return nil, err
+	}
+	cache.restore()
+	if err := cache.preload(o); err != nil {
+		return nil, err
+	}
+	cache.start()
+	return cache, nil
+}
+
+func (c *Cache[K, V]) start() {
+	if c.lazy {
+		c.cancel = func() {}
+		return
+	}
 	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.ttlEnforcer(ctx)
+}
 
-	storage := make(map[string]entry)
+// Clone returns an independent cache holding a copy of the live entries,
+// with the same settings and eviction order. The clone has its own lock and
+// background expiry and starts with zeroed statistics. Callbacks and loaders
+// are shared with c; the persist path and Events channel are not. A custom
+// EvictionPolicy can't be copied, so the clone of such a cache uses LRU.
+func (c *Cache[K, V]) Clone() *Cache[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	cache := &Cache{store: storage, size: size, ttl: ttl, cancel: cancel}
-	go cache.ttlEnforcer(ctx)
-	return cache, nil
+	clone := &Cache[K, V]{
+		store:         make(map[K]*entry[V], len(c.store)),
+		clock:         c.clock,
+		size:          c.size,
+		ttl:           c.ttl,
+		maxBytes:      c.maxBytes,
+		sizer:         c.sizer,
+		maxValueBytes: c.maxValueBytes,
+		maxWeight:     c.maxWeight,
+		codec:         c.codec,
+		compressAbove: c.compressAbove,
+		onEvict:       c.onEvict,
+		onExpire:      c.onExpire,
+		cloner:        c.cloner,
+		staleWindow:   c.staleWindow,
+		loader:        c.loader,
+		jitter:        c.jitter,
+		rand:          rand.Float64, // a caller's *rand.Rand is not safe to share
+		negativeTTL:   c.negativeTTL,
+		evictBatch:    c.evictBatch,
+		lazy:          c.lazy,
+		logf:          c.logf,
+		wake:          make(chan struct{}, 1),
+	}
+	pc, copyPolicy := c.policy.(policyCloner[K])
+	if copyPolicy {
+		clone.policy = pc.clone()
+	} else {
+		clone.policy = NewLRU[K]()
+	}
+
+	now := c.clock.Now()
+	for k, v := range c.store {
+		if c.expired(v, now) {
+			if copyPolicy {
+				clone.policy.Remove(k)
+			}
+			continue
+		}
+		e := &entry[V]{
+			value:    v.value,
+			packed:   v.packed,
+			size:     v.size,
+			weight:   v.weight,
+			ttl:      v.ttl,
+			expires:  v.expires,
+			index:    -1,
+			negative: v.negative,
+		}
+		clone.store[k] = e
+		clone.bytes += e.size
+		clone.weight.Add(int64(e.weight))
+		clone.schedule(k, e)
+		clone.tag(k, e, v.tags)
+		if !copyPolicy {
+			clone.policy.RecordInsert(k)
+		}
+	}
+
+	clone.start()
+	return clone
 }
 
-func (c *Cache) Close() {
+// Close stops the background expiry, if any. With WithPersistPath it then
+// saves the live entries to that file, returning any error from doing so.
+func (c *Cache[K, V]) Close() error {
 	c.cancel()
+
+	c.mu.Lock()
+	if c.events != nil {
+		close(c.events)
+		c.events = nil
+	}
+	c.unsubscribeAll()
+	c.mu.Unlock()
+
+	return c.persist()
 }
 
-func (c *Cache) Get(key string) (any, bool) {
-	c.mu.RLocker().Lock()
-	defer c.mu.RLocker().Unlock()
+// OnEvict registers fn to be called for every entry removed by capacity
+// eviction or TTL expiry. fn runs after the entry has been removed from the
+// cache and without the cache lock held, so it may safely call back into the
+// cache, even to store entries that cause further evictions.
+//
+// fn is called on the goroutine whose call removed the entries, or the
+// background expiry goroutine for the sweep, in the order they were removed,
+// once that call has released the lock and before it returns. Other
+// goroutines may see an entry gone, or already replaced, before fn runs for
+// it, and entries removed by different calls may be reported concurrently.
+// Passing nil removes a previously registered callback.
+func (c *Cache[K, V]) OnEvict(fn func(key K, value V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = fn
+}
 
-	if value, ok := c.store[key]; ok {
-		return value.value, true
+// Get returns the value for key. An entry whose TTL has elapsed is treated
+// as a miss and removed, even if the background sweep has not reached it.
+// With a loader configured (see WithLoader) a miss is loaded as by Fetch,
+// and a failed load is reported as a miss.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	if c.loader != nil {
+		value, err := c.Fetch(key)
+		return value, err == nil
 	}
-	return nil, false
+	value, _, ok := c.GetWithExpiry(key)
+	return value, ok
 }
 
-func (c *Cache) Set(key string, value any) {
+// GetWithExpiry is like Get but also returns the time at which the entry
+// expires.
+func (c *Cache[K, V]) GetWithExpiry(key K) (value V, expiresAt time.Time, ok bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	e, evicted := c.lookup(key, c.clock.Now(), nil)
+	if e != nil {
+		value, expiresAt, ok = c.valueOf(e), e.expires, true
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
 
-	if len(c.store) == c.size { // if we are at capacity, evict one
-		c.evictLRU()
+	c.notify(onEvict, evicted)
+	return value, expiresAt, ok
+}
+
+// GetMany returns the values of all keys present in the cache, taking the
+// lock once for the whole batch. Keys that miss are absent from the result.
+func (c *Cache[K, V]) GetMany(keys []K) map[K]V {
+	c.mu.Lock()
+	var evicted []item[K, V]
+	var e *entry[V]
+	now := c.clock.Now()
+	found := make(map[K]V, len(keys))
+	for _, k := range keys {
+		if e, evicted = c.lookup(k, now, evicted); e != nil {
+			found[k] = c.valueOf(e)
+		}
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return found
+}
+
+// lookup returns the live entry for key, recording the access with the policy
+// and counters. An expired entry is removed, appended to evicted and counted
+// as a miss. c.mu must be held for writing.
+func (c *Cache[K, V]) lookup(key K, now time.Time, evicted []item[K, V]) (*entry[V], []item[K, V]) {
+	e, ok := c.store[key]
+	if ok && c.expired(e, now) {
+		evicted = c.expire(key, e, evicted)
+		ok = false
 	}
-	newEntry := entry{
-		value: value,
-		time:  time.Now(),
+	if !ok || e.negative {
+		c.counters.misses.Add(1)
+		return nil, evicted
 	}
-	c.store[key] = newEntry
+	c.policy.RecordAccess(key)
+	c.counters.hits.Add(1)
+	return e, evicted
 }
 
-func (c *Cache) Keys() []string {
+// live returns the entry for key unless it is missing, expired or negative.
+func (c *Cache[K, V]) live(key K) (*entry[V], bool) {
+	e, ok := c.store[key]
+	if !ok || c.hidden(e, c.clock.Now()) {
+		return nil, false
+	}
+	return e, true
+}
+
+// GetStale returns the value for key even if its TTL has run out, as long as
+// the entry has not been removed yet, with stale reporting whether it has
+// expired. It can therefore return values that Get treats as a miss. Like
+// Peek, it leaves the eviction policy and counters untouched.
+func (c *Cache[K, V]) GetStale(key K) (value V, stale bool, ok bool) {
 	c.mu.RLock()
-	defer c.mu.RLocker().Unlock()
+	defer c.mu.RUnlock()
 
-	keys := make([]string, len(c.store))
-	i := 0
-	for k := range c.store {
-		keys[i] = k
-		i++
+	e, ok := c.store[key]
+	if !ok || e.negative {
+		return value, false, false
 	}
-	return keys
+	return c.valueOf(e), c.expired(e, c.clock.Now()), true
 }
 
-func (c *Cache) evictLRU() {
-	minTime, key := time.Now(), ""
-	for k, v := range c.store {
-		if v.time.Before(minTime) {
-			minTime, key = v.time, k
+// Peek returns the value for key without counting it as a use: the eviction
+// policy and the hit/miss counters are left untouched.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if e, ok := c.live(key); ok {
+		return c.valueOf(e), true
+	}
+	var zero V
+	return zero, false
+}
+
+// Has reports whether key holds a live entry. Like Peek, it does not count
+// as a use of the entry.
+func (c *Cache[K, V]) Has(key K) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.live(key)
+	return ok
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL stores value under key with its own ttl, overriding the cache
+// default. A ttl <= 0 uses the cache default.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	c.mu.Lock()
+	evicted, _ := c.insert(key, value, ttl, c.expiresAt(ttl), nil)
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+}
+
+// SetAndEvicted is like Set but also returns the entry evicted to make room,
+// if any. With WithEvictionBatch several entries may be evicted; the first
+// one chosen by the policy is returned. The eviction callback still runs for
+// all of them.
+func (c *Cache[K, V]) SetAndEvicted(key K, value V) (evictedKey K, evictedValue V, didEvict bool) {
+	c.mu.Lock()
+	evicted, _ := c.insert(key, value, c.ttl, c.expiresAt(c.ttl), nil)
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	if len(evicted) == 0 {
+		return evictedKey, evictedValue, false
+	}
+	return evicted[0].key, evicted[0].value, true
+}
+
+// SetIfAbsent stores value under key only if key is not already present and
+// reports whether it did.
+func (c *Cache[K, V]) SetIfAbsent(key K, value V) bool {
+	return c.setIf(key, value, false)
+}
+
+// Replace stores value under key only if key is already present and reports
+// whether it did.
+func (c *Cache[K, V]) Replace(key K, value V) bool {
+	return c.setIf(key, value, true)
+}
+
+func (c *Cache[K, V]) setIf(key K, value V, present bool) bool {
+	c.mu.Lock()
+	if _, ok := c.live(key); ok != present {
+		c.mu.Unlock()
+		return false
+	}
+	evicted, stored := c.insert(key, value, c.ttl, c.expiresAt(c.ttl), nil)
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return stored
+}
+
+// Update calls fn with the current value of key, or the zero value and false
+// if key is absent, and stores the value fn returns if fn also returns true.
+// fn runs under the cache lock, so it must not call back into the cache.
+// Storing restarts the entry's expiry; either way a present key counts as
+// used. Update reports whether a value was stored.
+func (c *Cache[K, V]) Update(key K, fn func(old V, found bool) (new V, store bool)) bool {
+	c.mu.Lock()
+	var old V
+	ttl := c.ttl
+	e, found := c.live(key)
+	if found {
+		old, ttl = c.valueOf(e), e.ttl
+	}
+	value, store := fn(old, found)
+	if !store {
+		if found {
+			c.policy.RecordAccess(key)
 		}
+		c.mu.Unlock()
+		return false
 	}
-	delete(c.store, key)
+	evicted, stored := c.insert(key, value, ttl, c.expiresAt(ttl), nil)
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return stored
+}
+
+// SetMany stores all items with the default TTL, taking the lock once for
+// the whole batch. Capacity is enforced as each item is inserted, so a batch
+// larger than the cache evicts its own earlier items.
+func (c *Cache[K, V]) SetMany(items map[K]V) {
+	c.mu.Lock()
+	var evicted []item[K, V]
+	for k, v := range items {
+		evicted, _ = c.insert(k, v, c.ttl, c.expiresAt(c.ttl), evicted)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+}
+
+// SetManyReport is like SetMany but also partitions the keys of items into
+// those still stored once the batch is in and those evicted by later items of
+// the same batch, or not stored at all. Entries evicted that were not part of
+// the batch are only reported to the eviction callback.
+func (c *Cache[K, V]) SetManyReport(items map[K]V) (stored []K, evicted []K) {
+	c.mu.Lock()
+	var removed []item[K, V]
+	inserted := make(map[K]bool, len(items))
+	for k, v := range items {
+		removed, inserted[k] = c.insert(k, v, c.ttl, c.expiresAt(c.ttl), removed)
+	}
+	for k := range items {
+		if _, ok := c.store[k]; ok && inserted[k] {
+			stored = append(stored, k)
+		} else {
+			evicted = append(evicted, k)
+		}
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, removed)
+	return stored, evicted
+}
+
+// TrySet is like Set but reports ErrValueTooLarge instead of silently
+// skipping a value larger than WithMaxValueBytes allows or larger than a
+// cache created by NewWithBytes can hold. As with Set, the value previously
+// stored under key is evicted when the new one is rejected.
+func (c *Cache[K, V]) TrySet(key K, value V) error {
+	size := c.sizeOf(value)
+	err := c.checkSize(size)
+
+	c.mu.Lock()
+	var evicted []item[K, V]
+	if err != nil {
+		evicted = c.discard(key, evicted)
+	} else {
+		evicted = c.insertSized(key, value, size, 1, c.ttl, c.expiresAt(c.ttl), evicted)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return err
+}
+
+// SetWithWeight is like Set but counts the entry as weight against the
+// capacity from WithMaxWeight, evicting entries until it fits. Entries stored
+// any other way weigh 1. A negative weight, or one over the capacity, is not
+// stored, and evicts the value key held before.
+func (c *Cache[K, V]) SetWithWeight(key K, value V, weight int) {
+	c.mu.Lock()
+	evicted, _ := c.insertWeighted(key, value, weight, c.ttl, c.expiresAt(c.ttl), nil)
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+}
+
+func (c *Cache[K, V]) sizeOf(value V) int64 {
+	if c.sizer == nil {
+		return 0
+	}
+	return c.sizer(value)
+}
+
+func (c *Cache[K, V]) checkSize(size int64) error {
+	if c.maxValueBytes > 0 && size > c.maxValueBytes {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrValueTooLarge, size, c.maxValueBytes)
+	}
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte capacity", ErrValueTooLarge, size, c.maxBytes)
+	}
+	return nil
+}
+
+// insert stores value under key, evicting entries first until it fits, and
+// appends any evicted entries to evicted. A value that can never fit is
+// skipped, and the value it would have replaced is evicted so it is not
+// served in its place. insert reports whether value was stored. c.mu must be
+// held.
+func (c *Cache[K, V]) insert(key K, value V, ttl time.Duration, expires time.Time, evicted []item[K, V]) ([]item[K, V], bool) {
+	return c.insertWeighted(key, value, 1, ttl, expires, evicted)
+}
+
+// insertWeighted is insert for an entry of the given weight.
+func (c *Cache[K, V]) insertWeighted(key K, value V, weight int, ttl time.Duration, expires time.Time, evicted []item[K, V]) ([]item[K, V], bool) {
+	if weight < 0 || c.maxWeight > 0 && weight > c.maxWeight {
+		return c.discard(key, evicted), false
+	}
+	size := c.sizeOf(value)
+	if c.checkSize(size) != nil {
+		return c.discard(key, evicted), false
+	}
+	return c.insertSized(key, value, size, weight, ttl, expires, evicted), true
+}
+
+// insertSized is insert for a value already measured and weighed and known
+// to fit.
+func (c *Cache[K, V]) insertSized(key K, value V, size int64, weight int, ttl time.Duration, expires time.Time, evicted []item[K, V]) []item[K, V] {
+	var tags []string // kept from an entry replaced below
+	if existing, ok := c.store[key]; ok {
+		if c.fitsInPlace(existing, size, weight) {
+			c.bytes += size - existing.size
+			c.weight.Add(int64(weight - existing.weight))
+			existing.value, existing.packed = c.pack(value)
+			existing.size, existing.weight = size, weight
+			existing.ttl, existing.expires = ttl, expires
+			existing.negative = false
+			c.schedule(key, existing)
+			if u, ok := c.policy.(UpdateRecorder[K]); ok {
+				u.RecordUpdate(key)
+			} else {
+				c.policy.RecordAccess(key)
+			}
+			c.wakeWaiters(key)
+			c.publish(key, value)
+			return evicted
+		}
+		// the grown value needs room, so make sure we don't evict it
+		tags = existing.tags
+		c.remove(key)
+	}
+
+	if c.full(size, weight) { // if we are at capacity, evict a batch or until the entry fits
+		for i := 0; i < c.evictBatch || c.full(size, weight); i++ {
+			var ok bool
+			if evicted, ok = c.evictOne(evicted); !ok {
+				break
+			}
+		}
+	}
+	e := &entry[V]{
+		size:    size,
+		weight:  weight,
+		ttl:     ttl,
+		expires: expires,
+		index:   -1,
+	}
+	e.value, e.packed = c.pack(value)
+	c.store[key] = e
+	c.schedule(key, e)
+	c.tag(key, e, tags)
+	c.bytes += size
+	c.weight.Add(int64(weight))
+	c.policy.RecordInsert(key)
+	c.wakeWaiters(key)
+	c.publish(key, value)
+	return evicted
+}
+
+// discard evicts the entry for key, if any, because a write replacing it was
+// rejected, and appends it to evicted.
+func (c *Cache[K, V]) discard(key K, evicted []item[K, V]) []item[K, V] {
+	e, ok := c.store[key]
+	if !ok {
+		return evicted
+	}
+	if e.negative || c.expired(e, c.clock.Now()) {
+		return c.expire(key, e, evicted)
+	}
+	c.remove(key)
+	value := c.valueOf(e)
+	c.counters.evictions.Add(1)
+	c.emit(key, value, ReasonLRU)
+	return append(evicted, item[K, V]{key, value, false})
+}
+
+// expiresAt returns when an entry written now with ttl expires, spread by the
+// configured jitter. c.mu must be held.
+func (c *Cache[K, V]) expiresAt(ttl time.Duration) time.Time {
+	if c.jitter > 0 {
+		// uniform in [-jitter*ttl, +jitter*ttl)
+		ttl += time.Duration((2*c.rand() - 1) * c.jitter * float64(ttl))
+	}
+	return c.clock.Now().Add(ttl)
+}
+
+// fitsInPlace reports whether existing can take a value of the given size
+// and weight without evicting anything.
+func (c *Cache[K, V]) fitsInPlace(existing *entry[V], size int64, weight int) bool {
+	if c.maxBytes > 0 && c.bytes-existing.size+size > c.maxBytes {
+		return false
+	}
+	return c.maxWeight == 0 || int(c.weight.Load())-existing.weight+weight <= c.maxWeight
+}
+
+// full reports whether an entry of the given size and weight needs an
+// eviction first.
+func (c *Cache[K, V]) full(size int64, weight int) bool {
+	if c.maxWeight > 0 && int(c.weight.Load())+weight > c.maxWeight {
+		return true
+	}
+	if c.maxBytes > 0 {
+		return c.bytes+size > c.maxBytes
+	}
+	return len(c.store) >= c.size
+}
+
+// Resize changes the maximum number of entries, immediately evicting entries
+// chosen by the policy if the cache holds more than newSize.
+func (c *Cache[K, V]) Resize(newSize int) error {
+
+	if newSize <= 0 {
+		return fmt.Errorf("size should be greater than zero")
+	}
+
+	c.mu.Lock()
+	if c.maxBytes > 0 {
+		c.mu.Unlock()
+		return fmt.Errorf("can't resize a cache bounded by bytes")
+	}
+	c.size = newSize
+	var evicted []item[K, V]
+	for len(c.store) > c.size {
+		var ok bool
+		if evicted, ok = c.evictOne(evicted); !ok {
+			break
+		}
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return nil
+}
+
+// Cap returns the maximum number of entries, or zero for a cache bounded by
+// bytes.
+func (c *Cache[K, V]) Cap() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.size
+}
+
+// Touch restarts the expiry clock of key using the TTL it was stored with and
+// reports whether the key was present.
+func (c *Cache[K, V]) Touch(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.live(key)
+	if !ok {
+		return false
+	}
+	e.expires = c.expiresAt(e.ttl)
+	c.schedule(key, e)
+	return true
+}
+
+// Expire gives key a new TTL counted from now, without changing its value,
+// and reports whether the key was present. A ttl <= 0 expires the key
+// immediately.
+func (c *Cache[K, V]) Expire(key K, ttl time.Duration) bool {
+	c.mu.Lock()
+	e, ok := c.live(key)
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	var evicted []item[K, V]
+	if ttl <= 0 {
+		evicted = c.expire(key, e, evicted)
+	} else {
+		e.ttl, e.expires = ttl, c.expiresAt(ttl)
+		c.schedule(key, e)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return true
+}
+
+// TTL returns how long key has left to live.
+func (c *Cache[K, V]) TTL(key K) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.live(key)
+	if !ok {
+		return 0, false
+	}
+	return e.expires.Sub(c.clock.Now()), true
+}
+
+// Delete removes key from the cache and reports whether it was present. An
+// entry whose TTL has run out counts as absent and is removed as expired.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	e, ok := c.store[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	if c.hidden(e, c.clock.Now()) {
+		evicted := c.expire(key, e, nil)
+		onEvict := c.onEvict
+		c.mu.Unlock()
+
+		c.notify(onEvict, evicted)
+		return false
+	}
+	c.remove(key)
+	c.emit(key, c.valueOf(e), ReasonDeleted)
+	c.mu.Unlock()
+	return true
+}
+
+// Pop removes key from the cache and returns the value it held. Of several
+// concurrent callers popping the same key, only one receives the value.
+func (c *Cache[K, V]) Pop(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.live(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	value := c.valueOf(e)
+	c.remove(key)
+	c.emit(key, value, ReasonDeleted)
+	return value, true
 }
 
-func (c *Cache) evictExpired() {
+// Shrink copies the entries into a map sized to hold just them, letting the
+// memory kept by the old map after many deletions be collected. Values,
+// expiries and eviction order are unchanged. The built-in policies are
+// compacted the same way.
+func (c *Cache[K, V]) Shrink() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	fmt.Println("Eviction Timer will run")
-	now := time.Now()
+
+	store := make(map[K]*entry[V], len(c.store))
+	for k, e := range c.store {
+		store[k] = e
+	}
+	c.store = store
+	if pc, ok := c.policy.(policyCloner[K]); ok {
+		c.policy = pc.clone()
+	}
+}
+
+// Clear removes every entry from the cache. The cache remains usable.
+func (c *Cache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.store {
+		c.policy.Remove(k)
+		if !e.negative {
+			c.publishRemoved(k)
+		}
+	}
+	c.store = make(map[K]*entry[V])
+	c.bytes = 0
+	c.weight.Store(0)
+	c.tags = nil
+	c.expiries = nil
+}
+
+// Keys returns the live keys, skipping entries whose TTL has elapsed but
+// which have not yet been swept, as Len does.
+func (c *Cache[K, V]) Keys() []K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]K, 0, len(c.store))
+	now := c.clock.Now()
+	for k, v := range c.store {
+		if !c.hidden(v, now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// KeysMatching returns the live keys for which match returns true. match runs
+// under the read lock, so it must not call back into the cache.
+func (c *Cache[K, V]) KeysMatching(match func(key K) bool) []K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []K
+	now := c.clock.Now()
 	for k, v := range c.store {
-		if now.Sub(v.time) > c.ttl {
-			delete(c.store, k)
+		if !c.hidden(v, now) && match(k) {
+			keys = append(keys, k)
 		}
 	}
+	return keys
+}
+
+// KeysWithPrefix returns the live keys of c that start with prefix.
+func KeysWithPrefix[K ~string, V any](c *Cache[K, V], prefix string) []K {
+	return c.KeysMatching(func(key K) bool {
+		return strings.HasPrefix(string(key), prefix)
+	})
 }
 
-func (c *Cache) ttlEnforcer(ctx context.Context) {
-	timer := time.NewTicker(c.ttl)
-	for {
-		select {
-		case <-timer.C:
-			c.evictExpired()
-		case <-ctx.Done():
+// Range calls fn for each live entry until fn returns false. Expired entries
+// that have not been swept yet are skipped. Range holds the read lock while
+// iterating, so fn must not modify the cache.
+func (c *Cache[K, V]) Range(fn func(key K, value V) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now()
+	for k, v := range c.store {
+		if c.hidden(v, now) {
+			continue
+		}
+		if !fn(k, c.valueOf(v)) {
 			return
 		}
 	}
 }
+
+// Entries returns a copy of all live entries taken under a single read lock.
+// Like Peek, it does not count as a use of the entries.
+func (c *Cache[K, V]) Entries() map[K]V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now()
+	entries := make(map[K]V, len(c.store))
+	for k, v := range c.store {
+		if !c.hidden(v, now) {
+			entries[k] = c.valueOf(v)
+		}
+	}
+	return entries
+}
+
+// Len returns the number of live entries in the cache. Entries whose TTL
+// has elapsed but which have not yet been swept by the background eviction
+// are not counted.
+func (c *Cache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now, n := c.clock.Now(), 0
+	for _, v := range c.store {
+		if !c.hidden(v, now) {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Cache[K, V]) expired(e *entry[V], now time.Time) bool {
+	return now.After(e.expires)
+}
+
+// hidden reports whether e should be invisible to readers.
+func (c *Cache[K, V]) hidden(e *entry[V], now time.Time) bool {
+	return e.negative || c.expired(e, now)
+}
+
+// copyOf returns value, or a copy of it made by the WithCopyOnGet cloner.
+func (c *Cache[K, V]) copyOf(value V) V {
+	if c.cloner == nil {
+		return value
+	}
+	return c.cloner(value)
+}
+
+// evictOne removes the entry chosen by the eviction policy and appends it to
+// evicted, unless it was a negative entry, and reports whether there was an
+// entry to remove.
+func (c *Cache[K, V]) evictOne(evicted []item[K, V]) ([]item[K, V], bool) {
+	var key K
+	var e *entry[V]
+	for e == nil {
+		var ok bool
+		if key, ok = c.policy.Evict(); !ok {
+			return evicted, false
+		}
+		e = c.store[key] // nil if a custom policy returned a key we don't hold
+	}
+	if e.negative {
+		c.drop(key, e)
+		return evicted, true
+	}
+	value := c.valueOf(e)
+	evicted = append(evicted, item[K, V]{key, value, false})
+	c.drop(key, e)
+	c.counters.evictions.Add(1)
+	c.emit(key, value, ReasonLRU)
+	c.logf("cache: evicted %v to make room", key)
+	return evicted, true
+}
+
+func (c *Cache[K, V]) remove(key K) {
+	c.policy.Remove(key)
+	c.drop(key, c.store[key])
+}
+
+// drop deletes key from the store without notifying the policy.
+func (c *Cache[K, V]) drop(key K, e *entry[V]) {
+	c.untag(key, e)
+	c.unschedule(e)
+	c.bytes -= e.size
+	c.weight.Add(-int64(e.weight))
+	delete(c.store, key)
+}
+
+// expire removes the expired entry e and appends it to evicted. A negative
+// entry is removed without a trace.
+func (c *Cache[K, V]) expire(key K, e *entry[V], evicted []item[K, V]) []item[K, V] {
+	c.remove(key)
+	if e.negative {
+		return evicted
+	}
+	c.counters.expirations.Add(1)
+	value := c.valueOf(e)
+	c.emit(key, value, ReasonExpired)
+	return append(evicted, item[K, V]{key, value, true})
+}
+
+// notify passes the removed entries to the expiry observer, for those that
+// expired, and to fn, the eviction callback read while c.mu was held. It
+// must be called without c.mu held.
+func (c *Cache[K, V]) notify(fn func(key K, value V), evicted []item[K, V]) {
+	for _, it := range evicted {
+		if it.expired && c.onExpire != nil {
+			c.onExpire(it.key, it.value)
+		}
+		if fn != nil {
+			fn(it.key, it.value)
+		}
+	}
+}
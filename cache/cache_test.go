@@ -0,0 +1,838 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCache returns a cache built by New that is closed when the test ends.
+func newCache[K comparable, V any](t testing.TB, size int, ttl time.Duration, opts ...Option) *Cache[K, V] {
+	t.Helper()
+	c, err := New[K, V](size, ttl, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestDelete(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	c.Set("a", 1)
+
+	if !c.Delete("a") {
+		t.Fatal("Delete(a) = false, want true")
+	}
+	if v, ok := c.Get("a"); ok || v != 0 {
+		t.Fatalf("Get(a) after Delete = %v, %v; want 0, false", v, ok)
+	}
+	if c.Delete("a") {
+		t.Fatal("Delete of a missing key = true, want false")
+	}
+}
+
+func TestGetRefreshesRecency(t *testing.T) {
+	c := newCache[string, int](t, 2, time.Minute)
+	c.Set("A", 1)
+	c.Set("B", 2)
+	c.Get("A")
+	c.Set("C", 3)
+
+	if c.Has("B") {
+		t.Error("B survived; want it evicted as least recently used")
+	}
+	if !c.Has("A") || !c.Has("C") {
+		t.Errorf("Keys() = %v, want A and C", c.Keys())
+	}
+}
+
+func TestTypedCaches(t *testing.T) {
+	ints := newCache[string, int](t, 10, time.Minute)
+	ints.Set("one", 1)
+	if v, ok := ints.Get("one"); !ok || v != 1 {
+		t.Errorf("Get(one) = %v, %v; want 1, true", v, ok)
+	}
+	if v, ok := ints.Get("two"); ok || v != 0 {
+		t.Errorf("Get(two) = %v, %v; want 0, false", v, ok)
+	}
+
+	type user struct {
+		Name string
+		Age  int
+	}
+	users := newCache[int, user](t, 10, time.Minute)
+	users.Set(7, user{"ann", 30})
+	if v, ok := users.Get(7); !ok || v != (user{"ann", 30}) {
+		t.Errorf("Get(7) = %v, %v; want {ann 30}, true", v, ok)
+	}
+	if v, ok := users.Get(8); ok || v != (user{}) {
+		t.Errorf("Get(8) = %v, %v; want zero user, false", v, ok)
+	}
+}
+
+func TestSetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	c.SetWithTTL("short", 1, time.Second)
+	c.SetWithTTL("long", 2, time.Hour)
+	c.SetWithTTL("default", 3, 0)
+
+	clock.Advance(2 * time.Second)
+	if _, ok := c.Get("short"); ok {
+		t.Error("short-TTL key still present after its TTL")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Error("long-TTL key missing before its TTL")
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("default"); ok {
+		t.Error("key set with ttl 0 outlived the cache default")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Error("long-TTL key missing before its TTL")
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	c.Clear()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len() after Clear = %d, want 0", n)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, ok := c.Get(k); ok {
+			t.Errorf("Get(%s) hit after Clear", k)
+		}
+	}
+
+	c.Set("c", 3)
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) after Clear = %v, %v; want 3, true", v, ok)
+	}
+}
+
+func TestOnEvictCounts(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[int, int](t, 2, time.Minute, WithClock(clock))
+	var calls atomic.Int32
+	c.OnEvict(func(int, int) { calls.Add(1) })
+
+	for i := range 4 {
+		c.Set(i, i) // the last two evict 0 and 1
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("%d callbacks after capacity eviction, want 2", n)
+	}
+
+	eventually(t, func() bool { return clock.waiting() == 1 }, "sweeper is not waiting")
+	clock.Advance(2 * time.Minute)
+	eventually(t, func() bool { return calls.Load() == 4 }, "expired entries were not reported")
+}
+
+// BenchmarkEvict compares inserting into a full cache, which evicts from the
+// back of the LRU list, with the full scan for the least recently used entry
+// that the list replaced.
+func BenchmarkEvict(b *testing.B) {
+	const size = 10000
+
+	b.Run("list", func(b *testing.B) {
+		c := newCache[int, int](b, size, time.Hour)
+		for i := range size {
+			c.Set(i, i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Set(size+i, i)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		used := make(map[int]time.Time, size)
+		for i := range size {
+			used[i] = time.Now()
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var oldest int
+			var at time.Time
+			for k, t := range used {
+				if at.IsZero() || t.Before(at) {
+					oldest, at = k, t
+				}
+			}
+			delete(used, oldest)
+			used[size+i] = time.Now()
+		}
+	})
+}
+
+func TestTouch(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	c.Set("a", 1)
+
+	clock.Advance(59 * time.Second)
+	if !c.Touch("a") {
+		t.Fatal("Touch(a) = false, want true")
+	}
+	clock.Advance(30 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("touched key expired at its original TTL")
+	}
+	if c.Touch("missing") {
+		t.Error("Touch of a missing key = true, want false")
+	}
+}
+
+func TestPeekDoesNotCountAsUse(t *testing.T) {
+	c := newCache[string, int](t, 2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek(a) = %v, %v; want 1, true", v, ok)
+	}
+	c.Set("c", 3)
+	if c.Has("a") {
+		t.Error("Peek saved a from eviction")
+	}
+	if st := c.Stats(); st.Hits != 0 {
+		t.Errorf("Peek counted %d hits, want 0", st.Hits)
+	}
+}
+
+func TestRange(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	want := map[string]int{"a": 1, "b": 2, "c": 3}
+	for k, v := range want {
+		c.Set(k, v)
+	}
+
+	got := make(map[string]int)
+	c.Range(func(k string, v int) bool {
+		got[k] = v
+		return true
+	})
+	if !maps.Equal(got, want) {
+		t.Errorf("Range visited %v, want %v", got, want)
+	}
+
+	visits := 0
+	c.Range(func(string, int) bool {
+		visits++
+		return false
+	})
+	if visits != 1 {
+		t.Errorf("Range visited %d entries after fn returned false, want 1", visits)
+	}
+}
+
+func TestNewWithBytes(t *testing.T) {
+	c, err := NewWithBytes[string, string](10, time.Minute, func(v string) int64 { return int64(len(v)) })
+	if err != nil {
+		t.Fatalf("NewWithBytes: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	c.Set("a", "aaaa")
+	c.Set("b", "bbbb")
+	c.Set("c", "cc")
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Len() = %d after storing 10 bytes, want 3", n)
+	}
+	c.Set("d", "dddd")
+	if c.Has("a") || !c.Has("b") || !c.Has("c") || !c.Has("d") {
+		t.Errorf("Keys() = %v after a 4 byte insert, want b, c and d", c.Keys())
+	}
+
+	c.Set("big", "01234567890")
+	if c.Has("big") {
+		t.Error("value larger than maxBytes was stored")
+	}
+	if n := c.Len(); n != 3 {
+		t.Errorf("Len() = %d after rejecting an oversized value, want 3", n)
+	}
+
+	c.Set("b", "01234567890")
+	if v, ok := c.Get("b"); ok {
+		t.Errorf("Get(b) = %q after an oversized overwrite, want a miss", v)
+	}
+}
+
+// TestConcurrentAccess is meant to be run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	c := newCache[int, int](t, 100, time.Minute)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				k := (g*1000 + i) % 150
+				c.Set(k, i)
+				c.Get(k)
+				if i%50 == 0 {
+					c.Keys()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > 100 {
+		t.Errorf("Len() = %d, want at most the capacity of 100", n)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	got := c.GetMany([]string{"a", "b", "missing"})
+	if want := map[string]int{"a": 1, "b": 2}; !maps.Equal(got, want) {
+		t.Errorf("GetMany = %v, want %v", got, want)
+	}
+	if st := c.Stats(); st.Hits != 2 || st.Misses != 1 {
+		t.Errorf("GetMany counted %d hits and %d misses, want 2 and 1", st.Hits, st.Misses)
+	}
+}
+
+func TestSetManyEvictsMidBatch(t *testing.T) {
+	c := newCache[int, int](t, 3, time.Minute)
+	c.Set(100, 0)
+	var evicted []int
+	c.OnEvict(func(k, _ int) { evicted = append(evicted, k) })
+
+	c.SetMany(map[int]int{1: 1, 2: 2, 3: 3, 4: 4})
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Len() = %d after an oversized batch, want 3", n)
+	}
+	if len(evicted) != 2 || evicted[0] != 100 {
+		t.Errorf("evicted %v, want the earlier key 100 then one key of the batch", evicted)
+	}
+}
+
+func TestGetWithExpiry(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Second)
+
+	if _, at, ok := c.GetWithExpiry("a"); !ok || !at.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("GetWithExpiry(a) expires at %v, %v; want %v", at, ok, clock.Now().Add(time.Minute))
+	}
+	if _, at, ok := c.GetWithExpiry("b"); !ok || !at.Equal(clock.Now().Add(time.Second)) {
+		t.Errorf("GetWithExpiry(b) expires at %v, %v; want %v", at, ok, clock.Now().Add(time.Second))
+	}
+}
+
+func TestSetIfAbsentRace(t *testing.T) {
+	for range 100 {
+		c := newCache[string, int](t, 10, time.Minute)
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if c.SetIfAbsent("k", i) {
+					wins.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := wins.Load(); n != 1 {
+			t.Fatalf("%d concurrent SetIfAbsent calls won, want 1", n)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	if c.Replace("k", 1) {
+		t.Fatal("Replace of a missing key = true, want false")
+	}
+	if c.Has("k") {
+		t.Fatal("Replace stored a missing key")
+	}
+	c.Set("k", 1)
+	if !c.Replace("k", 2) {
+		t.Fatal("Replace of a present key = false, want true")
+	}
+	if v, _ := c.Get("k"); v != 2 {
+		t.Errorf("Get(k) after Replace = %v, want 2", v)
+	}
+}
+
+func TestExpiredBeforeSweep(t *testing.T) {
+	clock := newFakeClock()
+	// without the background sweep, expired entries stay until read
+	c := newCache[string, int](t, 10, time.Hour, WithClock(clock), WithLazyExpiry())
+	c.SetWithTTL("short", 1, time.Second)
+	c.Set("long", 2)
+	clock.Advance(2 * time.Second)
+
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "long" || c.Len() != 1 {
+		t.Errorf("Keys() = %v and Len() = %d with short expired, want [long] and 1", keys, c.Len())
+	}
+	if v, ok := c.Get("short"); ok || v != 0 {
+		t.Errorf("Get(short) = %v, %v after it expired; want 0, false", v, ok)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Hour, WithClock(clock), WithLazyExpiry())
+	events := c.Events()
+	c.SetWithTTL("k", 1, time.Second)
+	clock.Advance(2 * time.Second)
+
+	if c.Delete("k") {
+		t.Error("Delete(k) = true for an expired entry")
+	}
+	select {
+	case ev := <-events:
+		if ev.Key != "k" || ev.Reason != ReasonExpired {
+			t.Errorf("Delete of an expired entry emitted %+v, want k with ReasonExpired", ev)
+		}
+	default:
+		t.Error("Delete of an expired entry emitted no event")
+	}
+	if st := c.Stats(); st.Expirations != 1 || c.Len() != 0 {
+		t.Errorf("Expirations = %d, Len() = %d after Delete of an expired entry, want 1 and 0", st.Expirations, c.Len())
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := newCache[int, int](t, 4, time.Minute)
+	for i := range 4 {
+		c.Set(i, i)
+	}
+
+	if err := c.Resize(8); err != nil {
+		t.Fatalf("Resize(8): %v", err)
+	}
+	if n, size := c.Len(), c.Cap(); n != 4 || size != 8 {
+		t.Fatalf("Len() = %d and Cap() = %d after growing, want 4 and 8", n, size)
+	}
+
+	if err := c.Resize(2); err != nil {
+		t.Fatalf("Resize(2): %v", err)
+	}
+	if n, evictions := c.Len(), c.Stats().Evictions; n != 2 || evictions != 2 {
+		t.Fatalf("Len() = %d with %d evictions after shrinking, want 2 and 2", n, evictions)
+	}
+	if c.Has(0) || c.Has(1) {
+		t.Errorf("Keys() = %v, want the two most recent keys", c.Keys())
+	}
+
+	if err := c.Resize(0); err == nil {
+		t.Error("Resize(0) succeeded")
+	}
+}
+
+func TestEntries(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 3, time.Minute, WithClock(clock), WithLazyExpiry())
+	c.SetWithTTL("old", 0, time.Second)
+	clock.Advance(2 * time.Second)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	want := map[string]int{"a": 1, "b": 2}
+	if got := c.Entries(); !maps.Equal(got, want) {
+		t.Fatalf("Entries() = %v, want %v", got, want)
+	}
+
+	c.Set("c", 3)
+	c.Set("d", 4) // a is still the least recently used
+	if c.Has("a") {
+		t.Error("Entries counted as a use of a")
+	}
+}
+
+func TestPopRace(t *testing.T) {
+	for range 100 {
+		c := newCache[string, int](t, 10, time.Minute)
+		c.Set("k", 1)
+		var got atomic.Int32
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if v, ok := c.Pop("k"); ok && v == 1 {
+					got.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := got.Load(); n != 1 {
+			t.Fatalf("%d concurrent Pop calls got the value, want 1", n)
+		}
+		if c.Has("k") {
+			t.Fatal("k still present after Pop")
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 2, time.Minute, WithClock(clock), WithLazyExpiry())
+	c.SetWithTTL("short", 0, time.Second)
+	clock.Advance(2 * time.Second)
+	if c.Has("short") {
+		t.Error("Has(short) = true after it expired")
+	}
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if !c.Has("a") {
+		t.Fatal("Has(a) = false, want true")
+	}
+	c.Set("c", 3)
+	if c.Has("a") {
+		t.Error("Has(a) counted as a use and kept a from eviction")
+	}
+	if st := c.Stats(); st.Hits != 0 || st.Misses != 0 {
+		t.Errorf("Has changed the hit and miss counts to %d and %d", st.Hits, st.Misses)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	const workers, appends = 50, 20
+	c := newCache[string, []int](t, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range appends {
+				c.Update("k", func(old []int, _ bool) ([]int, bool) {
+					return append(old, w*appends+i), true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := c.Peek("k")
+	if len(got) != workers*appends {
+		t.Fatalf("%d values after %d concurrent appends", len(got), workers*appends)
+	}
+	seen := make(map[int]bool, len(got))
+	for _, v := range got {
+		seen[v] = true
+	}
+	if len(seen) != workers*appends {
+		t.Errorf("%d distinct values, want %d", len(seen), workers*appends)
+	}
+
+	if c.Update("k", func(old []int, _ bool) ([]int, bool) { return nil, false }) {
+		t.Error("Update reported a store when fn declined")
+	}
+	if v, _ := c.Peek("k"); len(v) != workers*appends {
+		t.Error("declined Update changed the value")
+	}
+}
+
+func TestMaxValueBytes(t *testing.T) {
+	c := newCache[string, string](t, 10, time.Minute,
+		WithSizer(func(v string) int64 { return int64(len(v)) }), WithMaxValueBytes(4))
+	c.Set("small", "ok")
+
+	if err := c.TrySet("fits", "four"); err != nil {
+		t.Errorf("TrySet of a value at the limit: %v", err)
+	}
+	if err := c.TrySet("big", "too big"); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("TrySet of an oversized value = %v, want %v", err, ErrValueTooLarge)
+	}
+	c.Set("big", "too big")
+	if c.Has("big") {
+		t.Error("Set stored an oversized value")
+	}
+	if v, _ := c.Peek("small"); v != "ok" || c.Len() != 2 {
+		t.Errorf("Entries() = %v, want small and fits untouched", c.Entries())
+	}
+
+	var evicted []string
+	c.OnEvict(func(key string, _ string) { evicted = append(evicted, key) })
+	c.Set("small", "too big")
+	if v, ok := c.Get("small"); ok {
+		t.Errorf("Get(small) = %q after an oversized Set, want the old value gone", v)
+	}
+	if err := c.TrySet("fits", "too big"); !errors.Is(err, ErrValueTooLarge) || c.Has("fits") {
+		t.Errorf("oversized TrySet = %v and left fits present %v; want %v and gone", err, c.Has("fits"), ErrValueTooLarge)
+	}
+	if !slices.Equal(evicted, []string{"small", "fits"}) {
+		t.Errorf("eviction callback saw %v, want the two replaced keys", evicted)
+	}
+}
+
+func TestKeysWithPrefix(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	c.SetWithTTL("user:1:old", 0, time.Second)
+	clock.Advance(2 * time.Second)
+	c.Set("user:1:profile", 1)
+	c.Set("user:2:profile", 2)
+	c.Set("order:1", 3)
+
+	got := KeysWithPrefix(c, "user:")
+	slices.Sort(got)
+	if want := []string{"user:1:profile", "user:2:profile"}; !slices.Equal(got, want) {
+		t.Errorf("KeysWithPrefix(user:) = %v, want %v", got, want)
+	}
+	if got := KeysWithPrefix(c, "session:"); len(got) != 0 {
+		t.Errorf("KeysWithPrefix(session:) = %v, want none", got)
+	}
+}
+
+func TestEvictionBatch(t *testing.T) {
+	const size, batch = 10, 4
+	c := newCache[int, int](t, size, time.Minute, WithEvictionBatch(batch))
+	for i := range size {
+		c.Set(i, i)
+	}
+
+	c.Set(size, size)
+	if n, evictions := c.Len(), c.Stats().Evictions; n != size-batch+1 || evictions != batch {
+		t.Fatalf("Len() = %d with %d evictions after overflowing, want %d and %d", n, evictions, size-batch+1, batch)
+	}
+	for i := range batch - 1 {
+		c.Set(size+1+i, i)
+	}
+	if evictions := c.Stats().Evictions; evictions != batch {
+		t.Errorf("%d evictions while filling the room left by the batch, want %d", evictions, batch)
+	}
+	for i := range batch {
+		if c.Has(i) {
+			t.Errorf("Has(%d) = true, want the %d oldest keys evicted", i, batch)
+		}
+	}
+}
+
+// BenchmarkEvictionBatch reports how many Set calls of a full cache have to
+// evict, for a batch of one and of 64.
+func BenchmarkEvictionBatch(b *testing.B) {
+	const size = 10000
+	for _, batch := range []int{1, 64} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			c := newCache[int, int](b, size, time.Hour, WithEvictionBatch(batch))
+			for i := range size {
+				c.Set(i, i)
+			}
+			b.ResetTimer()
+			evicting := 0
+			for i := 0; i < b.N; i++ {
+				before := c.counters.evictions.Load()
+				c.Set(size+i, i)
+				if c.counters.evictions.Load() != before {
+					evicting++
+				}
+			}
+			b.ReportMetric(float64(evicting)/float64(b.N), "evicting-sets/op")
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	clone := c.Clone()
+	t.Cleanup(func() { clone.Close() })
+	clone.Set("c", 3)
+	clone.Delete("a")
+	c.Set("b", 20)
+
+	if want := map[string]int{"a": 1, "b": 20}; !maps.Equal(c.Entries(), want) {
+		t.Errorf("original holds %v, want %v", c.Entries(), want)
+	}
+	if want := map[string]int{"b": 2, "c": 3}; !maps.Equal(clone.Entries(), want) {
+		t.Errorf("clone holds %v, want %v", clone.Entries(), want)
+	}
+	if st := clone.Stats(); st != (Stats{Weight: 2}) {
+		t.Errorf("clone Stats() = %+v, want zeroed counters", st)
+	}
+}
+
+func TestWeightCapacity(t *testing.T) {
+	c := newCache[string, int](t, 100, time.Minute, WithMaxWeight(10))
+	c.SetWithWeight("a", 1, 4)
+	c.SetWithWeight("b", 2, 4)
+	c.Set("c", 3)
+	c.SetWithWeight("d", 4, 4) // 13 > 10, so a goes despite the room for 100 entries
+
+	if c.Has("a") || c.Len() != 3 {
+		t.Errorf("Keys() = %v, want a evicted for weight", c.Keys())
+	}
+	if w := c.Stats().Weight; w != 9 {
+		t.Errorf("Stats().Weight = %d, want 9", w)
+	}
+
+	c.SetWithWeight("huge", 5, 11)
+	if c.Has("huge") || c.Len() != 3 {
+		t.Error("entry heavier than the capacity was stored or evicted others")
+	}
+
+	c.SetWithWeight("c", 30, 11)
+	if v, ok := c.Get("c"); ok {
+		t.Errorf("Get(c) = %d after an overweight overwrite, want a miss", v)
+	}
+	if w := c.Stats().Weight; w != 8 {
+		t.Errorf("Stats().Weight = %d after dropping c, want 8", w)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[int, int](t, 100, time.Minute, WithClock(clock))
+	for i := range 100 {
+		c.SetWithTTL(i, i*10, time.Duration(i+1)*time.Minute)
+	}
+	for i := range 90 {
+		c.Delete(i)
+	}
+
+	c.Shrink()
+	for i := 90; i < 100; i++ {
+		if v, ok := c.Peek(i); !ok || v != i*10 {
+			t.Errorf("Peek(%d) = %v, %v after Shrink; want %d, true", i, v, ok, i*10)
+		}
+		if ttl, _ := c.TTL(i); ttl != time.Duration(i+1)*time.Minute {
+			t.Errorf("TTL(%d) = %v after Shrink, want %v", i, ttl, time.Duration(i+1)*time.Minute)
+		}
+	}
+	if err := c.Resize(5); err != nil {
+		t.Fatal(err)
+	}
+	for i := 90; i < 95; i++ {
+		if c.Has(i) {
+			t.Errorf("Has(%d) = true, want eviction order kept by Shrink", i)
+		}
+	}
+}
+
+func TestSetAndEvicted(t *testing.T) {
+	c := newCache[string, int](t, 3, time.Minute)
+	if _, _, ok := c.SetAndEvicted("a", 1); ok {
+		t.Fatal("SetAndEvicted reported an eviction from an empty cache")
+	}
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+
+	if k, v, ok := c.SetAndEvicted("d", 4); !ok || k != "b" || v != 2 {
+		t.Errorf("SetAndEvicted(d) = %v, %v, %v; want b, 2, true", k, v, ok)
+	}
+	if _, _, ok := c.SetAndEvicted("d", 5); ok {
+		t.Error("overwriting d reported an eviction")
+	}
+}
+
+func TestSetManyReport(t *testing.T) {
+	c := newCache[int, int](t, 3, time.Minute)
+	c.Set(-1, -1)
+	items := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4}
+
+	stored, evicted := c.SetManyReport(items)
+	slices.Sort(stored)
+	keys := c.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(stored, keys) {
+		t.Errorf("stored = %v, want the keys left in the cache %v", stored, keys)
+	}
+	if len(stored) != 3 || len(stored)+len(evicted) != len(items) {
+		t.Errorf("stored %v and evicted %v, want 3 and 2 of the %d items", stored, evicted, len(items))
+	}
+	for _, k := range evicted {
+		if c.Has(k) {
+			t.Errorf("%d reported evicted but still present", k)
+		}
+	}
+	if c.Has(-1) {
+		t.Error("key from before the batch survived a batch larger than the cache")
+	}
+}
+
+func TestEvictionCallbackReentersCache(t *testing.T) {
+	c := newCache[string, int](t, 2, time.Minute)
+	var order []string
+	c.OnEvict(func(key string, value int) {
+		order = append(order, key)
+		if key == "a" {
+			c.Set("from-callback", value) // evicts b in turn
+		}
+	})
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	done := make(chan struct{})
+	go func() {
+		c.Set("c", 3)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Set deadlocked in an eviction callback that calls Set")
+	}
+	if v, ok := c.Peek("from-callback"); !ok || v != 1 {
+		t.Errorf("Peek(from-callback) = %v, %v; want 1, true", v, ok)
+	}
+	if want := []string{"a", "b"}; !slices.Equal(order, want) {
+		t.Errorf("callbacks ran for %v, want %v", order, want)
+	}
+}
+
+func TestCopyOnGet(t *testing.T) {
+	c := newCache[string, []int](t, 10, time.Minute, WithCopyOnGet(slices.Clone[[]int]))
+	c.Set("k", []int{1, 2, 3})
+
+	got, _ := c.Get("k")
+	got[0] = 100
+	if again, _ := c.Get("k"); again[0] != 1 {
+		t.Errorf("Get(k) = %v after mutating an earlier result, want [1 2 3]", again)
+	}
+
+	updates, unsubscribe := c.Subscribe("k")
+	defer unsubscribe()
+	c.Set("k", []int{4, 5, 6})
+	(<-updates)[0] = 100
+	if again, _ := c.Get("k"); again[0] != 4 {
+		t.Errorf("Get(k) = %v after mutating a published value, want [4 5 6]", again)
+	}
+
+	shared := newCache[string, []int](t, 10, time.Minute)
+	shared.Set("k", []int{1, 2, 3})
+	got, _ = shared.Get("k")
+	got[0] = 100
+	if again, _ := shared.Get("k"); again[0] != 100 {
+		t.Error("Get copied the value without WithCopyOnGet")
+	}
+}
+
+func TestSetManyReportRejected(t *testing.T) {
+	c := newCache[string, string](t, 10, time.Minute,
+		WithSizer(func(v string) int64 { return int64(len(v)) }), WithMaxValueBytes(4))
+	c.Set("a", "old")
+
+	stored, evicted := c.SetManyReport(map[string]string{"a": "too big", "b": "ok"})
+	if !slices.Equal(stored, []string{"b"}) || !slices.Equal(evicted, []string{"a"}) {
+		t.Errorf("SetManyReport = %v, %v; want [b] stored and the rejected [a] not", stored, evicted)
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	ch    chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{clock: f, at: f.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- f.now
+	} else {
+		f.timers = append(f.timers, t)
+	}
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers now due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if t.at.After(f.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- f.now
+	}
+	f.timers = pending
+}
+
+// waiting returns how many timers are waiting to fire.
+func (f *fakeClock) waiting() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// due reports whether a waiting timer fires by the time the clock reaches at.
+func (f *fakeClock) due(at time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.timers {
+		if !t.at.After(at) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// eventually fails the test unless cond becomes true within a second. It is
+// for waiting on the background goroutines after advancing a fakeClock.
+func eventually(t *testing.T, cond func() bool, format string, args ...any) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExpiryAtTTLBoundary(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	c.Set("a", 1)
+	eventually(t, func() bool { return clock.waiting() == 1 }, "sweeper never waited for the expiry")
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get(a) missed exactly at the TTL; want a hit until it has passed")
+	}
+
+	clock.Advance(time.Nanosecond)
+	eventually(t, func() bool { return c.Stats().Expirations == 1 },
+		"sweeper did not remove a once its TTL passed")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) hit after the TTL passed")
+	}
+}
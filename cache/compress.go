@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"io"
+)
+
+// Codec converts values to and from bytes for WithCompression.
+type Codec[V any] interface {
+	Encode(value V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// gobCodec is the Codec used when WithCodec is not given.
+type gobCodec[V any] struct{}
+
+func (gobCodec[V]) Encode(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// pack returns what to store for value: value itself, or its gzipped
+// encoding if that is over the compression threshold and smaller. A value
+// the codec can't encode is stored as is. c.mu must be held.
+func (c *Cache[K, V]) pack(value V) (V, []byte) {
+	if c.codec == nil {
+		return value, nil
+	}
+	data, err := c.codec.Encode(value)
+	if err != nil || len(data) <= c.compressAbove {
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if zw.Close() != nil || buf.Len() >= len(data) {
+		return value, nil
+	}
+	var zero V
+	return zero, buf.Bytes()
+}
+
+// valueOf returns the value held by e, decompressing it if need be and
+// copied as by WithCopyOnGet. A value that fails to decode, which only
+// happens if the codec is not symmetric, is logged and read as the zero
+// value.
+func (c *Cache[K, V]) valueOf(e *entry[V]) V {
+	if e.packed == nil {
+		return c.copyOf(e.value)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(e.packed))
+	if err == nil {
+		var data []byte
+		if data, err = io.ReadAll(zr); err == nil {
+			var value V
+			if value, err = c.codec.Decode(data); err == nil {
+				return value
+			}
+		}
+	}
+	c.logf("cache: can't decode compressed value: %v", err)
+	var zero V
+	return zero
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompression(t *testing.T) {
+	c := newCache[string, string](t, 10, time.Minute, WithCompression(64))
+	large := strings.Repeat("compressible ", 100)
+	c.Set("large", large)
+	c.Set("small", "tiny")
+
+	if v, ok := c.Get("large"); !ok || v != large {
+		t.Fatalf("Get(large) did not return the original value")
+	}
+	if v, _ := c.Get("small"); v != "tiny" {
+		t.Errorf("Get(small) = %q, want tiny", v)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if e := c.store["large"]; e.packed == nil || len(e.packed) >= len(large) || e.value != "" {
+		t.Errorf("large value stored as %d packed bytes and %d plain bytes, want it compressed",
+			len(e.packed), len(e.value))
+	}
+	if e := c.store["small"]; e.packed != nil || e.value != "tiny" {
+		t.Error("value under the threshold was compressed")
+	}
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+)
+
+// Increment adds delta to the integer stored under key and returns the new
+// total. A missing key counts as zero. The existing entry keeps its expiry.
+// Increment fails, leaving the value unchanged, if the stored value is not of
+// an integer type or the total would overflow it.
+func (c *Cache[K, V]) Increment(key K, delta int64) (int64, error) {
+	c.mu.Lock()
+	var old V
+	e, found := c.live(key)
+	if found {
+		old = c.valueOf(e)
+	}
+	value, total, err := addDelta(old, delta)
+	if err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+
+	var evicted []item[K, V]
+	if found {
+		evicted, _ = c.insert(key, value, e.ttl, e.expires, evicted)
+	} else {
+		evicted, _ = c.insert(key, value, c.ttl, c.expiresAt(c.ttl), evicted)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return total, nil
+}
+
+// Decrement subtracts delta from the integer stored under key, see Increment.
+// A delta of math.MinInt64, which has no positive int64 counterpart, is
+// rejected.
+func (c *Cache[K, V]) Decrement(key K, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, fmt.Errorf("subtracting %d overflows", delta)
+	}
+	return c.Increment(key, -delta)
+}
+
+// addDelta adds delta to v, keeping the dynamic integer type of v. It
+// fails rather than wrap if the total doesn't fit that type or an int64.
+func addDelta[V any](v V, delta int64) (V, int64, error) {
+	var (
+		total  int64
+		result any
+		err    error
+	)
+	switch n := any(v).(type) {
+	case nil: // missing key with an interface value type
+		total = delta
+		result = total
+	case int:
+		result, total, err = add(n, delta)
+	case int8:
+		result, total, err = add(n, delta)
+	case int16:
+		result, total, err = add(n, delta)
+	case int32:
+		result, total, err = add(n, delta)
+	case int64:
+		result, total, err = add(n, delta)
+	case uint:
+		result, total, err = add(n, delta)
+	case uint8:
+		result, total, err = add(n, delta)
+	case uint16:
+		result, total, err = add(n, delta)
+	case uint32:
+		result, total, err = add(n, delta)
+	case uint64:
+		result, total, err = add(n, delta)
+	default:
+		return v, 0, fmt.Errorf("value of type %T is not an integer", v)
+	}
+	if err != nil {
+		return v, 0, err
+	}
+
+	value, ok := result.(V)
+	if !ok {
+		return v, 0, fmt.Errorf("value type %T can't hold an integer", v)
+	}
+	return value, total, nil
+}
+
+type integer interface {
+	int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64
+}
+
+// add returns n + delta as a T and as an int64, or an error if either can't
+// hold it.
+func add[T integer](n T, delta int64) (any, int64, error) {
+	start := int64(n)
+	total := start + delta
+	sum := T(total)
+	switch {
+	case n > 0 && start < 0, // a uint64 over the int64 range
+		delta > 0 && total < start,
+		delta < 0 && total > start,
+		int64(sum) != total,
+		(sum < 0) != (total < 0):
+		return n, 0, fmt.Errorf("adding %d to %T %d overflows", delta, n, n)
+	}
+	return sum, total, nil
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrementMissingKey(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	if n, err := c.Increment("k", 5); err != nil || n != 5 {
+		t.Fatalf("Increment of a missing key = %d, %v; want 5, nil", n, err)
+	}
+	if n, err := c.Decrement("k", 7); err != nil || n != -2 {
+		t.Fatalf("Decrement = %d, %v; want -2, nil", n, err)
+	}
+	if v, _ := c.Get("k"); v != -2 {
+		t.Errorf("Get(k) = %d, want -2", v)
+	}
+}
+
+func TestIncrementNotInteger(t *testing.T) {
+	c := newCache[string, any](t, 10, time.Minute)
+	c.Set("k", "text")
+	if _, err := c.Increment("k", 1); err == nil {
+		t.Fatal("Increment of a string succeeded")
+	}
+	if v, _ := c.Get("k"); v != "text" {
+		t.Errorf("failed Increment changed the value to %v", v)
+	}
+}
+
+func TestIncrementOverflow(t *testing.T) {
+	c := newCache[string, any](t, 10, time.Minute)
+	for _, tt := range []struct {
+		value any
+		delta int64
+	}{
+		{int8(math.MaxInt8), 1},
+		{int8(math.MinInt8), -1},
+		{uint8(0), -1},
+		{uint16(math.MaxUint16), 1},
+		{int64(math.MaxInt64), 1},
+		{uint64(math.MaxUint64), 0},
+	} {
+		c.Set("k", tt.value)
+		if n, err := c.Increment("k", tt.delta); err == nil {
+			t.Errorf("Increment(%T %v, %d) = %d, want an overflow error", tt.value, tt.value, tt.delta, n)
+		}
+		if v, _ := c.Get("k"); v != tt.value {
+			t.Errorf("Increment(%T %v, %d) stored %v", tt.value, tt.value, tt.delta, v)
+		}
+	}
+
+	c.Set("k", int64(5))
+	if n, err := c.Decrement("k", math.MinInt64); err == nil {
+		t.Errorf("Decrement(int64 5, MinInt64) = %d, want an overflow error", n)
+	}
+	if v, _ := c.Get("k"); v != int64(5) {
+		t.Errorf("Decrement(int64 5, MinInt64) stored %v", v)
+	}
+
+	c.Set("k", uint8(254))
+	if n, err := c.Increment("k", 1); err != nil || n != 255 {
+		t.Errorf("Increment(uint8 254, 1) = %d, %v; want 255, nil", n, err)
+	}
+}
+
+// TestIncrementConcurrent is meant to be run with -race.
+func TestIncrementConcurrent(t *testing.T) {
+	c := newCache[string, int64](t, 10, time.Minute)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Increment("k", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := c.Get("k"); v != 1000 {
+		t.Errorf("Get(k) = %d after 1000 concurrent increments", v)
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+// Reason describes why an entry left the cache.
+type Reason int
+
+const (
+	ReasonLRU     Reason = iota // evicted by the policy to make room
+	ReasonExpired               // its TTL ran out
+	ReasonDeleted               // removed by Delete
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonLRU:
+		return "lru"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Event reports an entry leaving the cache.
+type Event[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Reason Reason
+}
+
+// eventBuffer is the capacity of the channel returned by Events.
+const eventBuffer = 128
+
+// Events returns a channel receiving an Event for every evicted, expired or
+// deleted entry. The channel is buffered; when the buffer is full, new events
+// are dropped rather than blocking the cache. The channel is closed by Close.
+func (c *Cache[K, V]) Events() <-chan Event[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.events == nil {
+		c.events = make(chan Event[K, V], eventBuffer)
+	}
+	return c.events
+}
+
+// emit sends an event, and the zero value to the key's subscribers, without
+// blocking. c.mu must be held.
+func (c *Cache[K, V]) emit(key K, value V, reason Reason) {
+	c.publishRemoved(key)
+	if c.events == nil {
+		return
+	}
+	select {
+	case c.events <- Event[K, V]{Key: key, Value: value, Reason: reason}:
+	default:
+	}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	c := newCache[int, string](t, 2, time.Minute)
+	events := c.Events()
+	c.Set(1, "one")
+	c.Set(2, "two")
+	c.Set(3, "three")
+	c.Delete(2)
+
+	for _, want := range []Event[int, string]{
+		{Key: 1, Value: "one", Reason: ReasonLRU},
+		{Key: 2, Value: "two", Reason: ReasonDeleted},
+	} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("event %+v, want %+v", got, want)
+			}
+		default:
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	c.Close()
+	if _, ok := <-events; ok {
+		t.Error("Events channel still open after Close")
+	}
+}
+
+func TestEventsDropWhenFull(t *testing.T) {
+	c := newCache[int, int](t, 1, time.Minute)
+	events := c.Events()
+	for i := range eventBuffer + 10 {
+		c.Set(i, i) // never blocks, though nobody reads
+	}
+	if n := len(events); n != eventBuffer {
+		t.Errorf("%d events buffered, want %d", n, eventBuffer)
+	}
+}
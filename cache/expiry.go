@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+type expiryItem[K comparable, V any] struct {
+	key K
+	e   *entry[V]
+}
+
+// expiryHeap orders entries by expiry time, soonest first. Each entry keeps
+// its own index so it can be fixed or removed when it changes.
+type expiryHeap[K comparable, V any] []expiryItem[K, V]
+
+func (h expiryHeap[K, V]) Len() int           { return len(h) }
+func (h expiryHeap[K, V]) Less(i, j int) bool { return h[i].e.expires.Before(h[j].e.expires) }
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].e.index = i
+	h[j].e.index = j
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	it := x.(expiryItem[K, V])
+	it.e.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	n := len(old) - 1
+	it := old[n]
+	old[n] = expiryItem[K, V]{}
+	it.e.index = -1
+	*h = old[:n]
+	return it
+}
+
+// schedule adds e to the expiry heap, or repositions it after its expiry
+// changed. c.mu must be held.
+func (c *Cache[K, V]) schedule(key K, e *entry[V]) {
+	if e.index < 0 {
+		heap.Push(&c.expiries, expiryItem[K, V]{key, e})
+	} else {
+		heap.Fix(&c.expiries, e.index)
+	}
+	if e.index == 0 { // the sweeper may be waiting for a later expiry
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Cache[K, V]) unschedule(e *entry[V]) {
+	if e.index >= 0 {
+		heap.Remove(&c.expiries, e.index)
+	}
+}
+
+// FlushExpired removes all expired entries now rather than waiting for the
+// background sweep, and returns how many were removed.
+func (c *Cache[K, V]) FlushExpired() int {
+	return c.evictExpired()
+}
+
+func (c *Cache[K, V]) evictExpired() int {
+	c.mu.Lock()
+	var evicted []item[K, V]
+	now := c.clock.Now()
+	for len(c.expiries) > 0 && c.expired(c.expiries[0].e, now) {
+		it := c.expiries[0]
+		evicted = c.expire(it.key, it.e, evicted)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	if len(evicted) > 0 {
+		c.logf("cache: expired %d entries", len(evicted))
+	}
+	c.notify(onEvict, evicted)
+	return len(evicted)
+}
+
+// nextExpiry returns how long until the soonest entry expires.
+func (c *Cache[K, V]) nextExpiry() (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.expiries) == 0 {
+		return 0, false
+	}
+	// entries expire strictly after their expiry time
+	return max(c.expiries[0].e.expires.Sub(c.clock.Now()), 0) + time.Nanosecond, true
+}
+
+// ttlEnforcer sweeps expired entries, sleeping until the soonest expiry or
+// until an earlier one is scheduled.
+func (c *Cache[K, V]) ttlEnforcer(ctx context.Context) {
+	for {
+		var timer Timer
+		var fired <-chan time.Time
+		if wait, ok := c.nextExpiry(); ok {
+			timer = c.clock.NewTimer(wait)
+			fired = timer.C()
+		}
+
+		select {
+		case <-fired:
+			c.evictExpired()
+		case <-c.wake:
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSweepRepeats(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[int, int](t, 10, time.Second, WithClock(clock))
+
+	for i := 1; i <= 3; i++ {
+		c.Set(i, i)
+		eventually(t, func() bool { return clock.waiting() == 1 }, "sweep %d: sweeper is not waiting", i)
+		clock.Advance(2 * time.Second)
+		eventually(t, func() bool { return c.Stats().Expirations == uint64(i) },
+			"sweep %d: expired entry was not removed", i)
+	}
+}
+
+// BenchmarkSweep measures a sweep that finds one expired entry among many
+// live ones, against scanning every entry for expired ones.
+func BenchmarkSweep(b *testing.B) {
+	const size = 50000
+
+	b.Run("heap", func(b *testing.B) {
+		clock := newFakeClock()
+		c := newCache[int, int](b, size+1, 24*time.Hour, WithClock(clock), WithLazyExpiry())
+		for i := range size {
+			c.Set(i, i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.SetWithTTL(-1, i, time.Nanosecond)
+			clock.Advance(2 * time.Nanosecond)
+			c.FlushExpired()
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		now := time.Now()
+		expires := make(map[int]time.Time, size+1)
+		for i := range size {
+			expires[i] = now.Add(24 * time.Hour)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			expires[-1] = now.Add(time.Nanosecond)
+			now = now.Add(2 * time.Nanosecond)
+			for k, t := range expires {
+				if now.After(t) {
+					delete(expires, k)
+				}
+			}
+		}
+	})
+}
+
+func TestTTLJitterSpreadsExpiries(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[int, int](t, 100, time.Minute, WithClock(clock),
+		WithTTLJitter(0.5), WithRand(rand.New(rand.NewPCG(1, 2))))
+
+	expiries := make(map[time.Time]bool)
+	for i := range 100 {
+		c.Set(i, i)
+		_, at, _ := c.GetWithExpiry(i)
+		if d := at.Sub(clock.Now()); d < 30*time.Second || d >= 90*time.Second {
+			t.Fatalf("entry %d expires after %v, want within a minute ± 30s", i, d)
+		}
+		expiries[at] = true
+	}
+	if len(expiries) < 90 {
+		t.Errorf("100 entries written together share %d expiry times, want them spread", len(expiries))
+	}
+}
+
+func TestFlushExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[int, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	for i := range 3 {
+		c.SetWithTTL(i, i, time.Second)
+	}
+	c.Set(3, 3)
+	clock.Advance(2 * time.Second)
+
+	if n := c.FlushExpired(); n != 3 {
+		t.Errorf("FlushExpired() = %d, want 3", n)
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len() = %d after flushing, want 1", n)
+	}
+	if n := c.FlushExpired(); n != 0 {
+		t.Errorf("second FlushExpired() = %d, want 0", n)
+	}
+}
+
+func TestFlushExpiredWithSweeper(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[int, int](t, 100, time.Second, WithClock(clock))
+	for i := range 50 {
+		c.Set(i, i)
+	}
+	eventually(t, func() bool { return clock.waiting() == 1 }, "sweeper is not waiting")
+	clock.Advance(2 * time.Second) // wakes the sweeper while flushing below
+
+	var wg sync.WaitGroup
+	var flushed atomic.Int64
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			flushed.Add(int64(c.FlushExpired()))
+		}()
+	}
+	wg.Wait()
+	eventually(t, func() bool { return c.Stats().Expirations == 50 }, "not every entry expired")
+	if n := flushed.Load(); n > 50 {
+		t.Errorf("FlushExpired removed %d entries of 50", n)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() = %d, want 0", n)
+	}
+}
+
+func TestExpireShortens(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	c.Set("a", 1)
+	eventually(t, func() bool { return clock.waiting() == 1 }, "sweeper is not waiting")
+
+	if !c.Expire("a", 10*time.Second) {
+		t.Fatal("Expire(a) = false, want true")
+	}
+	soon := clock.Now().Add(11 * time.Second)
+	eventually(t, func() bool { return clock.due(soon) }, "sweeper did not reschedule for the shorter TTL")
+	clock.Advance(11 * time.Second)
+	eventually(t, func() bool { return c.Stats().Expirations == 1 }, "a was not swept at its shortened TTL")
+
+	if c.Expire("missing", time.Second) {
+		t.Error("Expire(missing) = true, want false")
+	}
+}
+
+func TestTTLDecreases(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	c.Set("a", 1)
+
+	for _, want := range []time.Duration{time.Minute, 40 * time.Second, 20 * time.Second} {
+		if ttl, ok := c.TTL("a"); !ok || ttl != want {
+			t.Errorf("TTL(a) = %v, %v; want %v, true", ttl, ok, want)
+		}
+		clock.Advance(20 * time.Second)
+	}
+	if _, ok := c.TTL("missing"); ok {
+		t.Error("TTL(missing) reported a key")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	clock := newFakeClock()
+	var logged []string
+	c := newCache[string, int](t, 1, time.Second, WithClock(clock), WithLazyExpiry(),
+		WithLogger(func(format string, args ...any) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+		}))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	clock.Advance(2 * time.Second)
+	c.FlushExpired()
+
+	want := []string{"cache: evicted a to make room", "cache: expired 1 entries"}
+	if !slices.Equal(logged, want) {
+		t.Errorf("logged %q, want %q", logged, want)
+	}
+}
+
+func TestGetStale(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	c.Set("a", 1)
+
+	if v, stale, ok := c.GetStale("a"); !ok || stale || v != 1 {
+		t.Errorf("GetStale(a) = %v, %v, %v; want 1, false, true", v, stale, ok)
+	}
+	clock.Advance(2 * time.Minute)
+	if v, stale, ok := c.GetStale("a"); !ok || !stale || v != 1 {
+		t.Errorf("GetStale(a) after its TTL = %v, %v, %v; want 1, true, true", v, stale, ok)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get(a) hit the expired entry")
+	}
+	if _, _, ok := c.GetStale("a"); ok {
+		t.Error("GetStale(a) found the entry after Get removed it")
+	}
+}
+
+func TestLazyExpiryStartsNoGoroutine(t *testing.T) {
+	clock := newFakeClock()
+	before := runtime.NumGoroutine()
+	caches := make([]*Cache[string, int], 10)
+	for i := range caches {
+		caches[i] = newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+		caches[i].Set("a", 1)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after creating 10 lazy caches, want at most %d", after, before)
+	}
+
+	c := caches[0]
+	clock.Advance(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get(a) hit after its TTL")
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestExpiryObserver(t *testing.T) {
+	clock := newFakeClock()
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	c := newCache[string, int](t, 2, time.Second, WithClock(clock),
+		WithExpiryObserver(func(key string, _ int) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[key]++
+		}))
+	c.Set("evicted", 0)
+	c.Set("a", 1)
+	c.Set("b", 2) // evicts the first key
+	eventually(t, func() bool { return clock.waiting() == 1 }, "sweeper is not waiting")
+	clock.Advance(2 * time.Second)
+	eventually(t, func() bool { return c.Stats().Expirations == 2 }, "sweeper did not expire a and b")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := map[string]int{"a": 1, "b": 1}; !maps.Equal(seen, want) {
+		t.Errorf("observer saw %v, want %v", seen, want)
+	}
+}
+
+func TestExpiryObserverOnGet(t *testing.T) {
+	clock := newFakeClock()
+	var seen []string
+	c := newCache[string, int](t, 10, time.Second, WithClock(clock), WithLazyExpiry(),
+		WithExpiryObserver(func(key string, _ int) { seen = append(seen, key) }))
+	c.Set("a", 1)
+	clock.Advance(2 * time.Second)
+
+	c.Get("a")
+	c.Get("a")
+	if !slices.Equal(seen, []string{"a"}) {
+		t.Errorf("observer saw %v, want [a]", seen)
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type jsonEntry[V any] struct {
+	Value     V         `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MarshalJSON encodes the live entries as a JSON object mapping each key to
+// its value and expiry time. Keys must be strings, integers or implement
+// encoding.TextMarshaler, and values must themselves be JSON encodable;
+// otherwise an error naming the problem is returned.
+func (c *Cache[K, V]) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	now := c.clock.Now()
+	entries := make(map[K]jsonEntry[V], len(c.store))
+	for k, v := range c.store {
+		if c.hidden(v, now) {
+			continue
+		}
+		entries[k] = jsonEntry[V]{Value: c.valueOf(v), ExpiresAt: v.expires}
+	}
+	c.mu.RUnlock()
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode cache entries as JSON: %w", err)
+	}
+	return b, nil
+}
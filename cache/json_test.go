@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	c.SetWithTTL("gone", 0, time.Second)
+	clock.Advance(2 * time.Second)
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"a":{"value":1,"expiresAt":"2024-01-01T00:01:02Z"},` +
+		`"b":{"value":2,"expiresAt":"2024-01-01T01:00:02Z"}}`
+	if string(got) != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+}
+
+func TestMarshalJSONUnsupported(t *testing.T) {
+	c := newCache[string, func()](t, 10, time.Minute)
+	c.Set("f", func() {})
+
+	if _, err := json.Marshal(c); err == nil {
+		t.Error("Marshal of a function value succeeded")
+	}
+}
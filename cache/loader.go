@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned by Fetch for a missing key when the cache has no
+// loader. Loaders return it (or wrap it) to report that a key does not exist,
+// which WithNegativeTTL can cache.
+var ErrNotFound = errors.New("key not found")
+
+// call is an in-flight or completed loader invocation for a single key.
+type call[V any] struct {
+	wg    sync.WaitGroup
+	value V
+	err   error
+}
+
+type loadGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Fetch returns the value for key, loading it on a miss with the loader set
+// by WithLoader. Loads are shared and refreshed as by GetOrLoad, and loader
+// errors are returned without being cached. Without a loader a miss returns
+// ErrNotFound.
+func (c *Cache[K, V]) Fetch(key K) (V, error) {
+	if c.loader == nil {
+		if value, _, ok := c.GetWithExpiry(key); ok {
+			return value, nil
+		}
+		var zero V
+		return zero, ErrNotFound
+	}
+	return c.GetOrLoad(key, func() (V, error) { return c.loader(key) })
+}
+
+// GetOrLoad returns the cached value for key. On a miss it calls loader,
+// stores the result and returns it. Concurrent callers missing the same key
+// share a single loader invocation and all receive its result. Errors from
+// loader are returned to every waiter and are not cached.
+//
+// If the cache has a stale window (see WithStaleWindow) and the entry expires
+// within it, the current value is returned immediately and loader is run in
+// the background to refresh it. At most one load per key is in flight.
+//
+// If the cache has a negative TTL (see WithNegativeTTL), a loader returning
+// ErrNotFound is remembered and ErrNotFound is returned without calling the
+// loader again until the negative TTL elapses or the key is set.
+func (c *Cache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	if value, expiresAt, ok := c.GetWithExpiry(key); ok {
+		if c.staleWindow > 0 && !c.clock.Now().Before(expiresAt.Add(-c.staleWindow)) {
+			c.refresh(key, loader)
+		}
+		return value, nil
+	}
+	var zero V
+	if c.isNegative(key) {
+		return zero, ErrNotFound
+	}
+
+	g := &c.loads
+	g.mu.Lock()
+	if cl, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		cl.wg.Wait()
+		if cl.err != nil {
+			return zero, cl.err
+		}
+		return c.copyOf(cl.value), nil
+	}
+	// a load may have completed between the miss above and taking g.mu
+	if value, ok := c.Peek(key); ok {
+		g.mu.Unlock()
+		return value, nil
+	}
+	if c.isNegative(key) {
+		g.mu.Unlock()
+		return zero, ErrNotFound
+	}
+	cl := g.start(key)
+	g.mu.Unlock()
+
+	c.load(key, loader, cl)
+	if cl.err != nil {
+		return zero, cl.err
+	}
+	return c.copyOf(cl.value), nil
+}
+
+// refresh reloads key in the background unless a load is already in flight.
+func (c *Cache[K, V]) refresh(key K, loader func() (V, error)) {
+	g := &c.loads
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.calls[key]; ok {
+		return
+	}
+	go c.load(key, loader, g.start(key))
+}
+
+// start registers a new in-flight call for key. g.mu must be held.
+func (g *loadGroup[K, V]) start(key K) *call[V] {
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	cl := &call[V]{}
+	cl.wg.Add(1)
+	g.calls[key] = cl
+	return cl
+}
+
+// load runs loader for the in-flight call cl, stores a successful result and
+// releases any waiters.
+func (c *Cache[K, V]) load(key K, loader func() (V, error), cl *call[V]) {
+	cl.value, cl.err = loader()
+	if cl.err == nil {
+		c.Set(key, cl.value)
+	} else if c.negativeTTL > 0 && errors.Is(cl.err, ErrNotFound) {
+		c.setNegative(key)
+	}
+
+	g := &c.loads
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	cl.wg.Done()
+}
+
+// GetContext returns the cached value for key. On a miss it runs loader with
+// ctx and stores its result. If ctx is done before loader returns, GetContext
+// returns ctx.Err() and the eventual loader result is discarded. Hits are
+// served regardless of the state of ctx.
+func (c *Cache[K, V]) GetContext(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	if value, _, ok := c.GetWithExpiry(key); ok { // not Get, which would run the cache's own loader
+		return value, nil
+	}
+
+	var zero V
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	done := make(chan *call[V], 1)
+	go func() {
+		cl := &call[V]{}
+		cl.value, cl.err = loader(ctx)
+		done <- cl
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return zero, res.err
+		}
+		c.Set(key, res.value)
+		return c.copyOf(res.value), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// setNegative records that key does not exist for the negative TTL, unless a
+// value was stored in the meantime. Negative entries are not values: they
+// are invisible to readers, subscribers and waiters, and only take room the
+// cache has free rather than evicting an entry for it.
+func (c *Cache[K, V]) setNegative(key K) {
+	c.mu.Lock()
+	if _, ok := c.live(key); ok {
+		c.mu.Unlock()
+		return
+	}
+	var evicted []item[K, V]
+	if e, ok := c.store[key]; ok { // expired, or an older negative entry
+		evicted = c.expire(key, e, evicted)
+	}
+	var zero V
+	size := c.sizeOf(zero)
+	if !c.full(size, 1) {
+		e := &entry[V]{
+			size:     size,
+			weight:   1,
+			ttl:      c.negativeTTL,
+			expires:  c.expiresAt(c.negativeTTL),
+			index:    -1,
+			negative: true,
+		}
+		c.store[key] = e
+		c.schedule(key, e)
+		c.bytes += size
+		c.weight.Add(1)
+		c.policy.RecordInsert(key)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+}
+
+func (c *Cache[K, V]) isNegative(key K) bool {
+	if c.negativeTTL <= 0 {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.store[key]
+	return ok && e.negative && !c.expired(e, c.clock.Now())
+}
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var ready, done sync.WaitGroup
+	errs := make(chan error, 100)
+	for range 100 {
+		ready.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			ready.Done()
+			if v, err := c.GetOrLoad("cold", loader); err != nil || v != 42 {
+				errs <- fmt.Errorf("GetOrLoad = %v, %v; want 42, nil", v, err)
+			}
+		}()
+	}
+	ready.Wait()
+	close(release)
+	done.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+}
+
+func TestGetOrLoadDoesNotCacheErrors(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	errBackend := errors.New("backend down")
+	calls := 0
+	loader := func() (int, error) {
+		calls++
+		return 0, errBackend
+	}
+
+	for range 2 {
+		if _, err := c.GetOrLoad("k", loader); !errors.Is(err, errBackend) {
+			t.Fatalf("GetOrLoad error = %v, want %v", err, errBackend)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("loader ran %d times, want 2", calls)
+	}
+	if c.Has("k") {
+		t.Error("failed load was cached")
+	}
+}
+
+func TestGetContextCancelled(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	c.Set("hit", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	loader := func(context.Context) (int, error) { return 2, nil }
+
+	if _, err := c.GetContext(ctx, "miss", loader); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetContext on a miss = %v, want %v", err, context.Canceled)
+	}
+	if c.Has("miss") {
+		t.Error("GetContext stored a value despite the cancelled context")
+	}
+	if v, err := c.GetContext(ctx, "hit", loader); err != nil || v != 1 {
+		t.Errorf("GetContext on a hit = %v, %v; want 1, nil", v, err)
+	}
+}
+
+func TestStaleWindowRefresh(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithStaleWindow(10*time.Second))
+	c.Set("k", 1)
+	clock.Advance(55 * time.Second)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	refresh := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 2, nil
+	}
+	for range 2 {
+		// returns while the refresh is still blocked
+		if v, err := c.GetOrLoad("k", refresh); err != nil || v != 1 {
+			t.Fatalf("GetOrLoad in the stale window = %v, %v; want 1, nil", v, err)
+		}
+	}
+	close(release)
+
+	eventually(t, func() bool { v, _ := c.Peek("k"); return v == 2 }, "stale value was not refreshed")
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d refreshes ran, want 1", n)
+	}
+}
+
+func TestReadThroughLoader(t *testing.T) {
+	errBackend := errors.New("backend down")
+	calls := 0
+	c := newCache[string, int](t, 10, time.Minute, WithLoader(func(key string) (int, error) {
+		calls++
+		if key == "bad" {
+			return 0, errBackend
+		}
+		return len(key), nil
+	}))
+
+	for range 2 {
+		if v, ok := c.Get("abc"); !ok || v != 3 {
+			t.Fatalf("Get(abc) = %v, %v; want 3, true", v, ok)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader ran %d times for a key already loaded, want 1", calls)
+	}
+	if _, err := c.Fetch("bad"); !errors.Is(err, errBackend) {
+		t.Errorf("Fetch(bad) error = %v, want %v", err, errBackend)
+	}
+	if c.Has("bad") {
+		t.Error("failed load was cached")
+	}
+}
+
+func TestGetContextSkipsCacheLoader(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute, WithLoader(func(string) (int, error) {
+		t.Error("GetContext ran the cache's loader")
+		return 0, nil
+	}))
+
+	v, err := c.GetContext(context.Background(), "k", func(context.Context) (int, error) { return 7, nil })
+	if err != nil || v != 7 {
+		t.Errorf("GetContext = %v, %v; want 7, nil", v, err)
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	clock := newFakeClock()
+	var removed []string
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry(),
+		WithNegativeTTL(time.Second),
+		WithEvictionCallback(func(key string, _ int) { removed = append(removed, key) }))
+	events := c.Events()
+	updates, unsubscribe := c.Subscribe("k")
+	defer unsubscribe()
+
+	calls := 0
+	missing := func() (int, error) {
+		calls++
+		return 0, ErrNotFound
+	}
+	for range 2 {
+		if _, err := c.GetOrLoad("k", missing); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetOrLoad error = %v, want %v", err, ErrNotFound)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader ran %d times within the negative TTL, want 1", calls)
+	}
+
+	clock.Advance(2 * time.Second)
+	c.FlushExpired()
+	if _, err := c.GetOrLoad("k", missing); !errors.Is(err, ErrNotFound) || calls != 2 {
+		t.Errorf("GetOrLoad after the negative TTL = %v with %d loads, want %v with 2", err, calls, ErrNotFound)
+	}
+	if c.Delete("k") {
+		t.Error("Delete(k) = true for a key only known to be missing")
+	}
+
+	if len(removed) != 0 {
+		t.Errorf("eviction callback saw negative entries %v", removed)
+	}
+	if st := c.Stats(); st.Expirations != 0 || st.Evictions != 0 {
+		t.Errorf("negative entries counted as %d expirations and %d evictions", st.Expirations, st.Evictions)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("negative entry emitted %+v", ev)
+	case v := <-updates:
+		t.Errorf("negative entry published %v to subscribers", v)
+	default:
+	}
+}
+
+func TestNegativeTTLOverriddenBySet(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry(),
+		WithNegativeTTL(time.Minute))
+
+	calls := 0
+	missing := func() (int, error) {
+		calls++
+		return 0, ErrNotFound
+	}
+	if _, err := c.GetOrLoad("k", missing); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, ErrNotFound)
+	}
+
+	c.Set("k", 7)
+	if v, ok := c.Get("k"); !ok || v != 7 {
+		t.Errorf("Get(k) = %d, %v after Set within the negative TTL, want 7, true", v, ok)
+	}
+	if v, err := c.GetOrLoad("k", missing); err != nil || v != 7 {
+		t.Errorf("GetOrLoad(k) = %d, %v after Set within the negative TTL, want 7, nil", v, err)
+	}
+	if calls != 1 {
+		t.Errorf("loader ran %d times, want 1", calls)
+	}
+}
+
+func TestNegativeTTLDoesNotEvict(t *testing.T) {
+	c := newCache[string, int](t, 1, time.Minute, WithNegativeTTL(time.Minute))
+	c.Set("a", 1)
+
+	if _, err := c.GetOrLoad("missing", func() (int, error) { return 0, ErrNotFound }); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, ErrNotFound)
+	}
+	if !c.Has("a") {
+		t.Error("remembering a missing key evicted a")
+	}
+}
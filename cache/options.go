@@ -0,0 +1,411 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Option configures optional behaviour of a Cache.
+type Option func(*options)
+
+// Generic values are held as any and checked against the cache's key and
+// value types when the cache is built.
+type options struct {
+	size          int
+	ttl           time.Duration
+	maxBytes      int64
+	maxValueBytes int64
+	maxWeight     int
+	sizer         any // Sizer[V]
+
+	policy   any // EvictionPolicy[K]
+	onEvict  any // func(K, V)
+	onExpire any // func(K, V)
+	cloner   any // func(V) V
+	loader   any // func(K) (V, error)
+	clock    Clock
+
+	staleWindow time.Duration
+	jitter      float64
+	rand        *rand.Rand
+	persistPath string
+	negativeTTL time.Duration
+	evictBatch  int
+	lazy        bool
+	logf        func(format string, args ...any)
+
+	compress  bool
+	threshold int
+	codec     any // Codec[V]
+
+	preload any // []KeyValue[K, V]
+	dropped any // func([]K)
+}
+
+const (
+	defaultSize = 1024
+	defaultTTL  = time.Minute
+)
+
+// WithSize sets the maximum number of entries.
+func WithSize(size int) Option {
+	return func(o *options) {
+		o.size = size
+	}
+}
+
+// WithTTL sets the default time to live of entries.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+func withBytes[V any](maxBytes int64, sizer Sizer[V]) Option {
+	return func(o *options) {
+		o.maxBytes, o.sizer = maxBytes, sizer
+	}
+}
+
+// WithSizer sets how the size of values is measured for WithMaxValueBytes.
+func WithSizer[V any](sizer Sizer[V]) Option {
+	return func(o *options) {
+		o.sizer = sizer
+	}
+}
+
+// WithMaxValueBytes makes Set skip, and TrySet reject, values larger than
+// limit as measured by the sizer from WithSizer. Either way the value the
+// key held before is evicted.
+func WithMaxValueBytes(limit int64) Option {
+	return func(o *options) {
+		o.maxValueBytes = limit
+	}
+}
+
+// WithMaxWeight bounds the total weight of the entries, as given to
+// SetWithWeight, in addition to any entry or byte limit.
+func WithMaxWeight(maxWeight int) Option {
+	return func(o *options) {
+		o.maxWeight = maxWeight
+	}
+}
+
+// WithEvictionCallback registers fn as by Cache.OnEvict.
+func WithEvictionCallback[K comparable, V any](fn func(key K, value V)) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// WithExpiryObserver registers fn to be called for every entry removed
+// because its TTL ran out, whether by the background sweep, FlushExpired or a
+// read finding it expired, but not for entries evicted to make room. Like an
+// OnEvict callback, fn runs after the entry is removed and without the cache
+// lock held; it is called before the eviction callback for the same entry.
+func WithExpiryObserver[K comparable, V any](fn func(key K, value V)) Option {
+	return func(o *options) {
+		o.onExpire = fn
+	}
+}
+
+// WithCopyOnGet makes the cache hand out cloner(value) instead of the stored
+// value itself, so callers may modify what they get without affecting the
+// cache. This covers reads, Subscribe updates, eviction callbacks, events,
+// Update and Save. cloner should make a deep copy; it runs with the cache lock
+// held. By default values are handed out as stored.
+func WithCopyOnGet[V any](cloner func(value V) V) Option {
+	return func(o *options) {
+		o.cloner = cloner
+	}
+}
+
+// WithPolicy sets the eviction policy used when the cache is at capacity.
+// The default is NewLRU.
+func WithPolicy[K comparable](policy EvictionPolicy[K]) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// lfu selects NewLFU for the cache's key type, see WithLFU.
+type lfu struct{}
+
+// WithLFU selects least frequently used eviction, as WithPolicy(NewLFU[K]())
+// without naming the key type.
+func WithLFU() Option {
+	return func(o *options) {
+		o.policy = lfu{}
+	}
+}
+
+// WithClock sets the clock used for expiry. The default is the system clock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithStaleWindow makes GetOrLoad refresh entries in the background once they
+// are within d of expiring, while still serving the current value.
+func WithStaleWindow(d time.Duration) Option {
+	return func(o *options) {
+		o.staleWindow = d
+	}
+}
+
+// WithLoader makes the cache read-through: Get and Fetch call loader on a
+// miss and store its result.
+func WithLoader[K comparable, V any](loader func(key K) (V, error)) Option {
+	return func(o *options) {
+		o.loader = loader
+	}
+}
+
+// WithTTLJitter spreads each entry's expiry uniformly over ttl ± fraction*ttl
+// so entries written together don't all expire together. fraction must be in
+// [0, 1).
+func WithTTLJitter(fraction float64) Option {
+	return func(o *options) {
+		o.jitter = fraction
+	}
+}
+
+// WithRand sets the random source used for TTL jitter. The default is the
+// global source of math/rand/v2. r must not be shared with other goroutines,
+// so NewSharded rejects it.
+func WithRand(r *rand.Rand) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// WithPersistPath makes Close save the live entries to path and the cache
+// load them back from path when created. A missing or corrupt file starts
+// the cache empty. Entries are gob encoded, see Cache.Save.
+func WithPersistPath(path string) Option {
+	return func(o *options) {
+		o.persistPath = path
+	}
+}
+
+// WithNegativeTTL makes GetOrLoad and Fetch remember for ttl that a loader
+// returned ErrNotFound, so repeated lookups of a missing key don't reach the
+// loader. Storing a value for the key clears the negative entry.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithEvictionBatch makes a full cache evict n entries at once, leaving room
+// for the next n inserts. The default is 1.
+func WithEvictionBatch(n int) Option {
+	return func(o *options) {
+		o.evictBatch = n
+	}
+}
+
+// WithLazyExpiry stops the cache from starting a background goroutine to
+// remove expired entries. Expired entries are still never returned, and are
+// removed when read or by FlushExpired, but otherwise stay in memory and
+// count towards capacity until evicted.
+func WithLazyExpiry() Option {
+	return func(o *options) {
+		o.lazy = true
+	}
+}
+
+// WithLogger sets a printf style function receiving eviction and expiry
+// diagnostics. It may be called with the cache lock held, so it must not
+// call back into the cache. By default nothing is logged.
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(o *options) {
+		o.logf = logf
+	}
+}
+
+// WithCompression stores values whose encoding, by the codec from WithCodec
+// or gob by default, is larger than threshold bytes gzip compressed. Reads
+// decompress them again, so callers always see the original value. Values
+// are encoded and compressed with the cache lock held.
+func WithCompression(threshold int) Option {
+	return func(o *options) {
+		o.compress, o.threshold = true, threshold
+	}
+}
+
+// WithCodec sets how values are turned into bytes for WithCompression.
+func WithCodec[V any](codec Codec[V]) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// KeyValue is a key and the value to store under it.
+type KeyValue[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// WithPreload fills a new cache with items, in order and after anything
+// restored by WithPersistPath, as if each was passed to Set. When they don't
+// all fit, the eviction policy decides which survive; with the default LRU
+// policy those are the last ones. If dropped is not nil it is called with
+// the keys of the items that were not stored or were evicted by later
+// items, before the constructor returns. Entries evicted are counted and
+// passed to the eviction callback as usual.
+func WithPreload[K comparable, V any](items []KeyValue[K, V], dropped func(keys []K)) Option {
+	return func(o *options) {
+		o.preload, o.dropped = items, dropped
+	}
+}
+
+func (c *Cache[K, V]) apply(o options) error {
+	if o.sizer != nil {
+		sizer, ok := o.sizer.(Sizer[V])
+		if !ok {
+			return fmt.Errorf("sizer %T does not match the cache value type", o.sizer)
+		}
+		c.sizer = sizer
+	}
+	if o.maxValueBytes < 0 {
+		return fmt.Errorf("max value bytes should not be negative")
+	}
+	if o.maxValueBytes > 0 && c.sizer == nil {
+		return fmt.Errorf("max value bytes requires a sizer")
+	}
+	c.maxValueBytes = o.maxValueBytes
+	if o.maxWeight < 0 {
+		return fmt.Errorf("max weight should not be negative")
+	}
+	c.maxWeight = o.maxWeight
+	if o.threshold < 0 {
+		return fmt.Errorf("compression threshold should not be negative")
+	}
+	if o.codec != nil && !o.compress {
+		return fmt.Errorf("codec requires compression")
+	}
+	if o.compress {
+		c.codec, c.compressAbove = gobCodec[V]{}, o.threshold
+		if o.codec != nil {
+			codec, ok := o.codec.(Codec[V])
+			if !ok {
+				return fmt.Errorf("codec %T does not match the cache value type", o.codec)
+			}
+			c.codec = codec
+		}
+	}
+	if _, ok := o.policy.(lfu); ok {
+		c.policy = NewLFU[K]()
+	} else if o.policy != nil {
+		policy, ok := o.policy.(EvictionPolicy[K])
+		if !ok {
+			return fmt.Errorf("policy %T does not match the cache key type", o.policy)
+		}
+		c.policy = policy
+	}
+	if o.onEvict != nil {
+		onEvict, ok := o.onEvict.(func(K, V))
+		if !ok {
+			return fmt.Errorf("eviction callback %T does not match the cache types", o.onEvict)
+		}
+		c.onEvict = onEvict
+	}
+	if o.cloner != nil {
+		cloner, ok := o.cloner.(func(V) V)
+		if !ok {
+			return fmt.Errorf("cloner %T does not match the cache value type", o.cloner)
+		}
+		c.cloner = cloner
+	}
+	if o.onExpire != nil {
+		onExpire, ok := o.onExpire.(func(K, V))
+		if !ok {
+			return fmt.Errorf("expiry observer %T does not match the cache types", o.onExpire)
+		}
+		c.onExpire = onExpire
+	}
+	if o.loader != nil {
+		loader, ok := o.loader.(func(K) (V, error))
+		if !ok {
+			return fmt.Errorf("loader %T does not match the cache types", o.loader)
+		}
+		c.loader = loader
+	}
+	if o.clock != nil {
+		c.clock = o.clock
+	}
+	if o.staleWindow < 0 {
+		return fmt.Errorf("stale window should not be negative")
+	}
+	c.staleWindow = o.staleWindow
+	if o.jitter < 0 || o.jitter >= 1 {
+		return fmt.Errorf("ttl jitter should be in [0, 1)")
+	}
+	c.jitter = o.jitter
+	c.persistPath = o.persistPath
+	c.lazy = o.lazy
+	if o.negativeTTL < 0 {
+		return fmt.Errorf("negative ttl should not be negative")
+	}
+	c.negativeTTL = o.negativeTTL
+	if o.evictBatch < 0 {
+		return fmt.Errorf("eviction batch should not be negative")
+	}
+	c.evictBatch = max(o.evictBatch, 1)
+	c.logf = func(string, ...any) {}
+	if o.logf != nil {
+		c.logf = o.logf
+	}
+	c.rand = rand.Float64
+	if o.rand != nil {
+		c.rand = o.rand.Float64
+	}
+	return nil
+}
+
+// preload stores the items from WithPreload, if any, and reports those that
+// didn't survive.
+func (c *Cache[K, V]) preload(o options) error {
+	if o.preload == nil {
+		return nil
+	}
+	items, ok := o.preload.([]KeyValue[K, V])
+	if !ok {
+		return fmt.Errorf("preload %T does not match the cache types", o.preload)
+	}
+	var report func([]K)
+	if o.dropped != nil {
+		if report, ok = o.dropped.(func([]K)); !ok {
+			return fmt.Errorf("preload report %T does not match the cache key type", o.dropped)
+		}
+	}
+
+	c.mu.Lock()
+	var evicted []item[K, V]
+	stored := make(map[K]bool, len(items)) // by the last item for each key
+	for _, it := range items {
+		evicted, stored[it.Key] = c.insert(it.Key, it.Value, c.ttl, c.expiresAt(c.ttl), evicted)
+	}
+	var dropped []K
+	for _, it := range items {
+		ok, pending := stored[it.Key]
+		if !pending {
+			continue // already reported
+		}
+		delete(stored, it.Key)
+		if _, present := c.store[it.Key]; !ok || !present {
+			dropped = append(dropped, it.Key)
+		}
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	if report != nil && len(dropped) > 0 {
+		report(dropped)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []string
+	c, err := NewWithOptions[string, int](
+		WithSize(2),
+		WithTTL(time.Hour),
+		WithClock(clock),
+		WithEvictionCallback(func(key string, _ int) { evicted = append(evicted, key) }),
+	)
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	c.Set("a", 1)
+	if ttl, _ := c.TTL("a"); ttl != time.Hour {
+		t.Errorf("TTL(a) = %v, want the WithTTL hour on the fake clock", ttl)
+	}
+	c.Set("b", 2)
+	c.Set("c", 3)
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want WithSize(2) to bound it", c.Len())
+	}
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("eviction callback saw %v, want [a]", evicted)
+	}
+}
+
+func TestNewWithOptionsDefaults(t *testing.T) {
+	c, err := NewWithOptions[string, int]()
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	if c.size != defaultSize || c.ttl != defaultTTL {
+		t.Errorf("size %d and ttl %v, want %d and %v", c.size, c.ttl, defaultSize, defaultTTL)
+	}
+}
+
+func TestNewWithOptionsInvalid(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"zero size":        {WithSize(0)},
+		"negative ttl":     {WithTTL(-time.Second)},
+		"mismatched types": {WithEvictionCallback(func(int, string) {})},
+	} {
+		if _, err := NewWithOptions[string, int](opts...); err == nil {
+			t.Errorf("%s: NewWithOptions succeeded, want an error", name)
+		}
+	}
+}
+
+func TestWithPreload(t *testing.T) {
+	var items []KeyValue[int, int]
+	for i := range 10 {
+		items = append(items, KeyValue[int, int]{i, i * 10})
+	}
+	var dropped []int
+	c := newCache[int, int](t, 3, time.Minute,
+		WithPreload(items, func(keys []int) { dropped = keys }))
+
+	keys := c.Keys()
+	slices.Sort(keys)
+	if want := []int{7, 8, 9}; !slices.Equal(keys, want) {
+		t.Errorf("Keys() = %v after preloading 0..9, want the last three %v", keys, want)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6}; !slices.Equal(dropped, want) {
+		t.Errorf("dropped %v, want %v", dropped, want)
+	}
+	if v, _ := c.Peek(9); v != 90 {
+		t.Errorf("Peek(9) = %d, want 90", v)
+	}
+}
+
+func TestWithPreloadDuplicateKeys(t *testing.T) {
+	var dropped []string
+	c := newCache[string, int](t, 2, time.Minute, WithPreload([]KeyValue[string, int]{
+		{"a", 1}, {"b", 2}, {"a", 3}, {"c", 4},
+	}, func(keys []string) { dropped = keys }))
+
+	if v, _ := c.Peek("a"); v != 3 || c.Has("b") {
+		t.Errorf("Entries() = %v, want the later a to count as its most recent use", c.Entries())
+	}
+	if !slices.Equal(dropped, []string{"b"}) {
+		t.Errorf("dropped %v, want [b]", dropped)
+	}
+}
+
+func TestWithPreloadFits(t *testing.T) {
+	called := false
+	c := newCache[string, int](t, 3, time.Minute,
+		WithPreload([]KeyValue[string, int]{{"a", 1}}, func([]string) { called = true }))
+
+	if called || !c.Has("a") {
+		t.Error("preloading an item that fits reported it dropped")
+	}
+}
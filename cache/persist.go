@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshot is the gob encoded form written by Save.
+type snapshot[K comparable, V any] struct {
+	Saved   time.Time
+	Entries []savedEntry[K, V]
+}
+
+type savedEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	TTL       time.Duration
+	Weight    int
+	Remaining time.Duration // lifetime left at snapshot.Saved
+}
+
+// Save writes all live entries, with their remaining TTLs, to w using gob.
+// If K or V are interface types, the concrete types stored in the cache must
+// be registered with gob.Register before calling Save or Load.
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	c.mu.RLock()
+	now := c.clock.Now()
+	snap := snapshot[K, V]{Saved: now, Entries: make([]savedEntry[K, V], 0, len(c.store))}
+	for k, v := range c.store {
+		if c.hidden(v, now) {
+			continue
+		}
+		snap.Entries = append(snap.Entries, savedEntry[K, V]{
+			Key:       k,
+			Value:     c.valueOf(v),
+			TTL:       v.ttl,
+			Weight:    v.weight,
+			Remaining: v.expires.Sub(now),
+		})
+	}
+	c.mu.RUnlock()
+
+	return gob.NewEncoder(w).Encode(snap)
+}
+
+// Load reads entries written by Save from r and adds them to the cache,
+// overwriting existing keys. Entries whose TTL ran out since they were saved
+// are dropped. Loading more entries than the cache holds evicts as Set would.
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	var snap snapshot[K, V]
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("can't decode cache snapshot: %w", err)
+	}
+
+	c.mu.Lock()
+	now := c.clock.Now()
+	elapsed := now.Sub(snap.Saved)
+	var evicted []item[K, V]
+	for _, e := range snap.Entries {
+		remaining := e.Remaining - elapsed
+		if remaining <= 0 {
+			continue
+		}
+		evicted, _ = c.insertWeighted(e.Key, e.Value, e.Weight, e.TTL, now.Add(remaining), evicted)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return nil
+}
+
+// restore loads the file set by WithPersistPath, if any. A missing or
+// unreadable file leaves the cache empty.
+func (c *Cache[K, V]) restore() {
+	if c.persistPath == "" {
+		return
+	}
+	f, err := os.Open(c.persistPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_ = c.Load(f) // Load decodes fully before storing, so a bad file stores nothing
+}
+
+// persist saves the cache to the file set by WithPersistPath, if any. It
+// writes to a temporary file first so a failed save never truncates the
+// previous snapshot.
+func (c *Cache[K, V]) persist() error {
+	if c.persistPath == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(c.persistPath), filepath.Base(c.persistPath)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("can't persist cache: %w", err)
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+
+	if err := c.Save(f); err != nil {
+		f.Close()
+		return fmt.Errorf("can't persist cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("can't persist cache: %w", err)
+	}
+	if err := os.Rename(f.Name(), c.persistPath); err != nil {
+		return fmt.Errorf("can't persist cache: %w", err)
+	}
+	return nil
+}
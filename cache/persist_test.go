@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	clock := newFakeClock()
+	src := newCache[string, int](t, 10, time.Minute, WithClock(clock))
+	src.SetWithTTL("short", 1, time.Second)
+	src.Set("long", 2)
+
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	later := newFakeClock()
+	later.Advance(2 * time.Second)
+	dst := newCache[string, int](t, 10, time.Minute, WithClock(later))
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if v, ok := dst.Get("long"); !ok || v != 2 {
+		t.Errorf("Get(long) after Load = %v, %v; want 2, true", v, ok)
+	}
+	if dst.Has("short") {
+		t.Error("entry whose TTL ran out since Save was loaded")
+	}
+	if ttl, _ := dst.TTL("long"); ttl != time.Minute-2*time.Second {
+		t.Errorf("TTL(long) after Load = %v, want %v", ttl, time.Minute-2*time.Second)
+	}
+}
+
+func TestPersistPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+	c, err := New[string, int](10, time.Minute, WithPersistPath(path))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := newCache[string, int](t, 10, time.Minute, WithPersistPath(path))
+	want := map[string]int{"a": 1, "b": 2}
+	if got := reopened.Entries(); !maps.Equal(got, want) {
+		t.Errorf("Entries() after reopening = %v, want %v", got, want)
+	}
+}
+
+func TestPersistPathCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+	if err := os.WriteFile(path, []byte("not gob"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newCache[string, int](t, 10, time.Minute, WithPersistPath(path))
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() = %d after loading a corrupt file, want 0", n)
+	}
+}
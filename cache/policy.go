@@ -0,0 +1,213 @@
+package cache
+
+import "container/list"
+
+// EvictionPolicy decides which key to evict when the cache is at capacity.
+// The cache calls RecordInsert when a new key is stored, RecordAccess when an
+// existing key is read or overwritten, and Remove when a key leaves the cache
+// for any other reason. All calls are made with the cache lock held, so
+// implementations need no locking of their own, but a policy must not be
+// shared between caches.
+type EvictionPolicy[K comparable] interface {
+	RecordInsert(key K)
+	RecordAccess(key K)
+	Remove(key K)
+	// Evict removes and returns the next key to evict. It reports false if
+	// the policy tracks no keys.
+	Evict() (K, bool)
+}
+
+// UpdateRecorder may be implemented by an EvictionPolicy that treats
+// overwriting an existing key differently from reading it. If implemented,
+// the cache calls RecordUpdate instead of RecordAccess when a key is
+// overwritten.
+type UpdateRecorder[K comparable] interface {
+	RecordUpdate(key K)
+}
+
+// policyCloner is implemented by the built-in policies so Cache.Clone can
+// copy their state.
+type policyCloner[K comparable] interface {
+	clone() EvictionPolicy[K]
+}
+
+// keyList is a list of keys with O(1) lookup of each key's element.
+type keyList[K comparable] struct {
+	order *list.List
+	elems map[K]*list.Element
+}
+
+func newKeyList[K comparable]() keyList[K] {
+	return keyList[K]{order: list.New(), elems: make(map[K]*list.Element)}
+}
+
+func (l keyList[K]) clone() keyList[K] {
+	cp := newKeyList[K]()
+	for e := l.order.Front(); e != nil; e = e.Next() {
+		cp.elems[e.Value.(K)] = cp.order.PushBack(e.Value)
+	}
+	return cp
+}
+
+func (l keyList[K]) pushFront(key K) {
+	if _, ok := l.elems[key]; !ok {
+		l.elems[key] = l.order.PushFront(key)
+	}
+}
+
+func (l keyList[K]) remove(key K) {
+	if e, ok := l.elems[key]; ok {
+		l.order.Remove(e)
+		delete(l.elems, key)
+	}
+}
+
+func (l keyList[K]) popBack() (K, bool) {
+	back := l.order.Back()
+	if back == nil {
+		var zero K
+		return zero, false
+	}
+	key := back.Value.(K)
+	l.remove(key)
+	return key, true
+}
+
+type lruPolicy[K comparable] struct {
+	keyList[K]
+}
+
+// NewLRU returns a policy that evicts the least recently used key. It is the
+// default policy.
+func NewLRU[K comparable]() EvictionPolicy[K] {
+	return &lruPolicy[K]{newKeyList[K]()}
+}
+
+func (p *lruPolicy[K]) clone() EvictionPolicy[K] { return &lruPolicy[K]{p.keyList.clone()} }
+func (p *lruPolicy[K]) RecordInsert(key K)       { p.pushFront(key) }
+func (p *lruPolicy[K]) Remove(key K)             { p.remove(key) }
+func (p *lruPolicy[K]) Evict() (K, bool)         { return p.popBack() }
+
+func (p *lruPolicy[K]) RecordAccess(key K) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+type fifoPolicy[K comparable] struct {
+	keyList[K]
+}
+
+// NewFIFO returns a policy that evicts the oldest inserted key, regardless of
+// how often or recently it was accessed.
+func NewFIFO[K comparable]() EvictionPolicy[K] {
+	return &fifoPolicy[K]{newKeyList[K]()}
+}
+
+func (p *fifoPolicy[K]) clone() EvictionPolicy[K] { return &fifoPolicy[K]{p.keyList.clone()} }
+func (p *fifoPolicy[K]) RecordInsert(key K)       { p.pushFront(key) }
+func (p *fifoPolicy[K]) RecordAccess(K)           {}
+func (p *fifoPolicy[K]) Remove(key K)             { p.remove(key) }
+func (p *fifoPolicy[K]) Evict() (K, bool)         { return p.popBack() }
+
+// lfuBucket holds the keys sharing one access count, oldest first.
+type lfuBucket[K comparable] struct {
+	freq int
+	keys *list.List
+}
+
+type lfuNode[K comparable] struct {
+	key    K
+	bucket *list.Element // element of lfuPolicy.buckets
+}
+
+type lfuPolicy[K comparable] struct {
+	buckets *list.List // *lfuBucket in ascending freq order
+	nodes   map[K]*list.Element
+}
+
+// NewLFU returns a policy that evicts the least frequently used key. Each
+// read counts as a use and overwriting a key resets its count. Keys with
+// equal counts are evicted oldest first, in the order they reached that
+// count.
+func NewLFU[K comparable]() EvictionPolicy[K] {
+	return &lfuPolicy[K]{buckets: list.New(), nodes: make(map[K]*list.Element)}
+}
+
+func (p *lfuPolicy[K]) clone() EvictionPolicy[K] {
+	cp := &lfuPolicy[K]{buckets: list.New(), nodes: make(map[K]*list.Element, len(p.nodes))}
+	for b := p.buckets.Front(); b != nil; b = b.Next() {
+		bucket := b.Value.(*lfuBucket[K])
+		nb := cp.buckets.PushBack(&lfuBucket[K]{freq: bucket.freq, keys: list.New()})
+		for e := bucket.keys.Front(); e != nil; e = e.Next() {
+			key := e.Value.(*lfuNode[K]).key
+			cp.nodes[key] = nb.Value.(*lfuBucket[K]).keys.PushBack(&lfuNode[K]{key: key, bucket: nb})
+		}
+	}
+	return cp
+}
+
+func (p *lfuPolicy[K]) RecordInsert(key K) {
+	if _, ok := p.nodes[key]; ok {
+		return
+	}
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket[K]).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket[K]{freq: 1, keys: list.New()})
+	}
+	p.nodes[key] = front.Value.(*lfuBucket[K]).keys.PushBack(&lfuNode[K]{key: key, bucket: front})
+}
+
+func (p *lfuPolicy[K]) RecordAccess(key K) {
+	elem, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	node := elem.Value.(*lfuNode[K])
+	cur := node.bucket
+	freq := cur.Value.(*lfuBucket[K]).freq
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket[K]).freq != freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket[K]{freq: freq + 1, keys: list.New()}, cur)
+	}
+	p.unlink(elem)
+	node.bucket = next
+	p.nodes[key] = next.Value.(*lfuBucket[K]).keys.PushBack(node)
+}
+
+// RecordUpdate resets the count of an overwritten key, since the new value
+// has not been used yet.
+func (p *lfuPolicy[K]) RecordUpdate(key K) {
+	if _, ok := p.nodes[key]; ok {
+		p.Remove(key)
+		p.RecordInsert(key)
+	}
+}
+
+func (p *lfuPolicy[K]) Remove(key K) {
+	if elem, ok := p.nodes[key]; ok {
+		p.unlink(elem)
+		delete(p.nodes, key)
+	}
+}
+
+func (p *lfuPolicy[K]) Evict() (K, bool) {
+	front := p.buckets.Front()
+	if front == nil {
+		var zero K
+		return zero, false
+	}
+	key := front.Value.(*lfuBucket[K]).keys.Front().Value.(*lfuNode[K]).key
+	p.Remove(key)
+	return key, true
+}
+
+// unlink removes elem from its bucket, dropping the bucket once empty.
+func (p *lfuPolicy[K]) unlink(elem *list.Element) {
+	bucket := elem.Value.(*lfuNode[K]).bucket
+	keys := bucket.Value.(*lfuBucket[K]).keys
+	keys.Remove(elem)
+	if keys.Len() == 0 {
+		p.buckets.Remove(bucket)
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// victim fills a cache of three with a, b and c, reads b three times, a three
+// times and c once, then inserts d and returns the key evicted for it.
+func victim(t *testing.T, opt Option) string {
+	t.Helper()
+	c := newCache[string, int](t, 3, time.Minute, opt)
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, 0)
+	}
+	for _, k := range []string{"b", "b", "b", "a", "a", "a", "c"} {
+		c.Get(k)
+	}
+	k, _, ok := c.SetAndEvicted("d", 0)
+	if !ok {
+		t.Fatal("inserting d into a full cache evicted nothing")
+	}
+	return k
+}
+
+func TestPolicyEvictionOrder(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opt  Option
+		want string
+	}{
+		{"LRU", WithPolicy(NewLRU[string]()), "b"},
+		{"FIFO", WithPolicy(NewFIFO[string]()), "a"},
+		{"LFU", WithPolicy(NewLFU[string]()), "c"},
+		{"WithLFU", WithLFU(), "c"},
+	} {
+		if got := victim(t, tt.opt); got != tt.want {
+			t.Errorf("%s evicted %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// ghostPolicy is an LRU that first offers a key it was never given.
+type ghostPolicy struct {
+	EvictionPolicy[string]
+	haunted bool
+}
+
+func (p *ghostPolicy) Evict() (string, bool) {
+	if !p.haunted {
+		p.haunted = true
+		return "ghost", true
+	}
+	return p.EvictionPolicy.Evict()
+}
+
+func TestPolicyUnknownKey(t *testing.T) {
+	c := newCache[string, int](t, 1, time.Minute, WithPolicy[string](&ghostPolicy{EvictionPolicy: NewLRU[string]()}))
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	if c.Has("a") || !c.Has("b") {
+		t.Errorf("Keys() = %v, want [b]", c.Keys())
+	}
+}
+
+func TestLFU(t *testing.T) {
+	c := newCache[string, int](t, 3, time.Minute, WithLFU())
+	c.Set("steady", 0)
+	c.Set("old", 0)
+	c.Set("new", 0)
+	for range 5 {
+		c.Get("steady")
+	}
+
+	if k, _, _ := c.SetAndEvicted("d", 0); k != "old" {
+		t.Fatalf("evicted %s, want old, the older of the unread keys", k)
+	}
+	if !c.Has("steady") {
+		t.Fatal("frequently read key was evicted")
+	}
+
+	c.Get("new")
+	c.Get("d")
+	c.Set("steady", 1) // overwriting resets its count below new and d
+	if k, _, _ := c.SetAndEvicted("e", 0); k != "steady" {
+		t.Errorf("evicted %s, want steady after its count was reset", k)
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"time"
+)
+
+// Sharded spreads keys over several independent caches, each with its own
+// lock, to reduce contention under concurrent load.
+type Sharded[K comparable, V any] struct {
+	shards []*Cache[K, V]
+	seed   maphash.Seed
+}
+
+// NewSharded creates a cache of the given number of shards whose capacities
+// add up to roughly size. opts apply to every shard; WithPolicy, WithRand,
+// WithPersistPath and WithPreload are rejected because they can't be shared
+// between shards, but WithLFU gives each shard its own policy.
+func NewSharded[K comparable, V any](shards, size int, ttl time.Duration, opts ...Option) (*Sharded[K, V], error) {
+
+	if shards <= 0 {
+		return nil, fmt.Errorf("shards should be greater than zero")
+	}
+
+	if size < shards {
+		return nil, fmt.Errorf("size should be at least the number of shards")
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if _, ok := o.policy.(lfu); !ok && o.policy != nil {
+		return nil, fmt.Errorf("policy can't be shared between shards")
+	}
+	if o.rand != nil {
+		return nil, fmt.Errorf("rand can't be shared between shards")
+	}
+	if o.persistPath != "" {
+		return nil, fmt.Errorf("persist path can't be shared between shards")
+	}
+	if o.preload != nil {
+		return nil, fmt.Errorf("preload can't be shared between shards")
+	}
+
+	s := &Sharded[K, V]{shards: make([]*Cache[K, V], shards), seed: maphash.MakeSeed()}
+	perShard := (size + shards - 1) / shards
+	for i := range s.shards {
+		shard, err := New[K, V](perShard, ttl, opts...)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.shards[i] = shard
+	}
+	return s, nil
+}
+
+func (s *Sharded[K, V]) shard(key K) *Cache[K, V] {
+	return s.shards[maphash.Comparable(s.seed, key)%uint64(len(s.shards))]
+}
+
+func (s *Sharded[K, V]) Close() error {
+	var errs []error
+	for _, shard := range s.shards {
+		if shard != nil {
+			errs = append(errs, shard.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sharded[K, V]) Get(key K) (V, bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *Sharded[K, V]) Set(key K, value V) {
+	s.shard(key).Set(key, value)
+}
+
+func (s *Sharded[K, V]) Delete(key K) bool {
+	return s.shard(key).Delete(key)
+}
+
+func (s *Sharded[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+func (s *Sharded[K, V]) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+func (s *Sharded[K, V]) Cap() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Cap()
+	}
+	return n
+}
+
+// Stats returns the counters summed over all shards.
+func (s *Sharded[K, V]) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		st := shard.Stats()
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Evictions += st.Evictions
+		total.Expirations += st.Expirations
+		total.Weight += st.Weight
+	}
+	return total
+}
+
+// ResetStats zeroes the counters of every shard.
+func (s *Sharded[K, V]) ResetStats() {
+	for _, shard := range s.shards {
+		shard.ResetStats()
+	}
+}
+
+// HitRatio returns the fraction of lookups over all shards that were hits.
+func (s *Sharded[K, V]) HitRatio() float64 {
+	return s.Stats().HitRatio()
+}
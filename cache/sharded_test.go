@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+type getSetter interface {
+	Get(key int) (int, bool)
+	Set(key int, value int)
+}
+
+// BenchmarkThroughput compares a single-lock cache with a sharded one under a
+// mixed read and write load from many goroutines.
+func BenchmarkThroughput(b *testing.B) {
+	const size = 10000
+
+	single := newCache[int, int](b, size, time.Hour)
+	sharded, err := NewSharded[int, int](16, size, time.Hour)
+	if err != nil {
+		b.Fatalf("NewSharded: %v", err)
+	}
+	b.Cleanup(func() { sharded.Close() })
+
+	for _, bm := range []struct {
+		name  string
+		cache getSetter
+	}{
+		{"single", single},
+		{"sharded", sharded},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					k := i % (2 * size)
+					if i%4 == 0 {
+						bm.cache.Set(k, i)
+					} else {
+						bm.cache.Get(k)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestNewShardedRejectsRand(t *testing.T) {
+	if _, err := NewSharded[int, int](4, 100, time.Minute, WithRand(rand.New(rand.NewPCG(1, 2)))); err == nil {
+		t.Error("NewSharded accepted a rand source shared by every shard")
+	}
+}
+
+func TestShardedHitRatio(t *testing.T) {
+	s, err := NewSharded[int, int](4, 40, time.Minute)
+	if err != nil {
+		t.Fatalf("NewSharded: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	for i := range 8 {
+		s.Set(i, i)
+	}
+	for i := range 16 {
+		s.Get(i) // 8 hits spread over the shards, then 8 misses
+	}
+
+	if r := s.HitRatio(); r != 0.5 {
+		t.Errorf("HitRatio() = %v, want 0.5", r)
+	}
+	s.ResetStats()
+	if r := s.HitRatio(); r != 0 {
+		t.Errorf("HitRatio() = %v after ResetStats, want 0", r)
+	}
+}
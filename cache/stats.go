@@ -0,0 +1,58 @@
+package cache
+
+import "sync/atomic"
+
+// Stats is a point-in-time snapshot of the cache counters.
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Weight      int64 // total weight of the stored entries, see SetWithWeight
+}
+
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// Stats returns the current hit, miss, eviction and expiration counters and
+// the total entry weight.
+// Reading them does not take the cache lock.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        c.counters.hits.Load(),
+		Misses:      c.counters.misses.Load(),
+		Evictions:   c.counters.evictions.Load(),
+		Expirations: c.counters.expirations.Load(),
+		Weight:      c.weight.Load(),
+	}
+}
+
+// ResetStats zeroes the hit, miss, eviction and expiration counters, starting
+// a new window for HitRatio. The total entry weight is not a counter and is
+// kept.
+func (c *Cache[K, V]) ResetStats() {
+	c.counters.hits.Store(0)
+	c.counters.misses.Store(0)
+	c.counters.evictions.Store(0)
+	c.counters.expirations.Store(0)
+}
+
+// HitRatio returns the fraction of lookups since the cache was created, or
+// since ResetStats, that were hits. It is zero before the first lookup.
+func (c *Cache[K, V]) HitRatio() float64 {
+	return c.Stats().HitRatio()
+}
+
+// HitRatio returns Hits as a fraction of all lookups, or zero if there were
+// none.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
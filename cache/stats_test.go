@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 2, time.Minute, WithClock(clock))
+	c.SetWithTTL("a", 1, time.Second)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+	c.Set("c", 3) // evicts b
+	clock.Advance(2 * time.Second)
+	c.Get("a") // expired, whether or not the sweep got to it first
+
+	want := Stats{Hits: 2, Misses: 2, Evictions: 1, Expirations: 1, Weight: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHitRatio(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	if r := c.HitRatio(); r != 0 {
+		t.Errorf("HitRatio() = %v before any lookup, want 0", r)
+	}
+
+	c.Set("a", 1)
+	for _, k := range []string{"a", "a", "a", "missing"} {
+		c.Get(k)
+	}
+	if r := c.HitRatio(); r != 0.75 {
+		t.Errorf("HitRatio() = %v after 3 hits and 1 miss, want 0.75", r)
+	}
+
+	c.ResetStats()
+	if r, st := c.HitRatio(), c.Stats(); r != 0 || st != (Stats{Weight: 1}) {
+		t.Errorf("HitRatio() = %v and Stats() = %+v after ResetStats, want 0 and only the weight", r, st)
+	}
+	c.Get("missing")
+	if r := c.HitRatio(); r != 0 {
+		t.Errorf("HitRatio() = %v after a single miss, want 0", r)
+	}
+}
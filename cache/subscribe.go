@@ -0,0 +1,70 @@
+package cache
+
+// subscriberBuffer is the capacity of each channel returned by Subscribe.
+const subscriberBuffer = 16
+
+// Subscribe returns a channel receiving the new value every time key is
+// stored, and the zero value every time it is deleted, evicted, expired or
+// cleared. Like Events, delivery never blocks the cache: updates are dropped
+// while the channel's buffer is full. The returned function unsubscribes and
+// closes the channel; Close does the same for all subscriptions.
+func (c *Cache[K, V]) Subscribe(key K) (<-chan V, func()) {
+	ch := make(chan V, subscriberBuffer)
+
+	c.mu.Lock()
+	if c.subs == nil {
+		c.subs = make(map[K]map[chan V]struct{})
+	}
+	if c.subs[key] == nil {
+		c.subs[key] = make(map[chan V]struct{})
+	}
+	c.subs[key][ch] = struct{}{}
+	c.mu.Unlock()
+
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if _, ok := c.subs[key][ch]; !ok {
+			return // already unsubscribed or closed
+		}
+		delete(c.subs[key], ch)
+		if len(c.subs[key]) == 0 {
+			delete(c.subs, key)
+		}
+		close(ch)
+	}
+}
+
+// publish sends each subscriber of key its own copy of value, as made by
+// copyOf, without blocking. c.mu must be held.
+func (c *Cache[K, V]) publish(key K, value V) {
+	for ch := range c.subs[key] {
+		select {
+		case ch <- c.copyOf(value):
+		default:
+		}
+	}
+}
+
+// publishRemoved sends the zero value to the subscribers of key without
+// blocking. c.mu must be held.
+func (c *Cache[K, V]) publishRemoved(key K) {
+	var zero V
+	for ch := range c.subs[key] {
+		select {
+		case ch <- zero:
+		default:
+		}
+	}
+}
+
+// unsubscribeAll closes every subscription. c.mu must be held.
+func (c *Cache[K, V]) unsubscribeAll() {
+	for _, chans := range c.subs {
+		for ch := range chans {
+			close(ch)
+		}
+	}
+	c.subs = nil
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	updates, unsubscribe := c.Subscribe("k")
+	other, unsubscribeOther := c.Subscribe("other")
+	defer unsubscribeOther()
+
+	c.Set("k", 1)
+	c.Set("k", 2)
+	c.Delete("k")
+	for _, want := range []int{1, 2, 0} {
+		if v := <-updates; v != want {
+			t.Errorf("received %d, want %d", v, want)
+		}
+	}
+	select {
+	case v := <-other:
+		t.Errorf("subscriber of another key received %d", v)
+	default:
+	}
+
+	unsubscribe()
+	c.Set("k", 3)
+	if v, ok := <-updates; ok {
+		t.Errorf("received %d after unsubscribing", v)
+	}
+	if _, ok := c.subs["k"]; ok {
+		t.Error("unsubscribing left the key's subscriber set behind")
+	}
+	unsubscribe() // a second call is harmless
+}
+
+func TestSubscribeDoesNotBlock(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	updates, unsubscribe := c.Subscribe("k")
+	defer unsubscribe()
+
+	for i := range subscriberBuffer + 5 {
+		c.Set("k", i)
+	}
+	if n := len(updates); n != subscriberBuffer {
+		t.Errorf("%d updates buffered, want %d with the rest dropped", n, subscriberBuffer)
+	}
+}
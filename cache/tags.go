@@ -0,0 +1,73 @@
+package cache
+
+// SetWithTags stores value under key with the default TTL and associates it
+// with tags, replacing any tags the key had before. Tags stay attached until
+// the entry leaves the cache or SetWithTags is called for the key again.
+func (c *Cache[K, V]) SetWithTags(key K, value V, tags ...string) {
+	c.mu.Lock()
+	evicted, stored := c.insert(key, value, c.ttl, c.expiresAt(c.ttl), nil)
+	if stored {
+		e := c.store[key]
+		c.untag(key, e)
+		c.tag(key, e, tags)
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+}
+
+// InvalidateTag deletes every entry carrying tag and returns how many live
+// entries were removed. Tagged entries found expired are removed as expired
+// and not counted.
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	c.mu.Lock()
+	var evicted []item[K, V]
+	now := c.clock.Now()
+	n := 0
+	for k := range c.tags[tag] {
+		e := c.store[k]
+		if c.expired(e, now) {
+			evicted = c.expire(k, e, evicted)
+			continue
+		}
+		c.remove(k)
+		c.emit(k, c.valueOf(e), ReasonDeleted)
+		n++
+	}
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(onEvict, evicted)
+	return n
+}
+
+func (c *Cache[K, V]) tag(key K, e *entry[V], tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if c.tags == nil {
+		c.tags = make(map[string]map[K]struct{})
+	}
+	e.tags = append([]string(nil), tags...)
+	for _, t := range tags {
+		keys, ok := c.tags[t]
+		if !ok {
+			keys = make(map[K]struct{})
+			c.tags[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// untag removes key from the index of every tag it carries.
+func (c *Cache[K, V]) untag(key K, e *entry[V]) {
+	for _, t := range e.tags {
+		keys := c.tags[t]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.tags, t)
+		}
+	}
+	e.tags = nil
+}
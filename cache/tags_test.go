@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	c.SetWithTags("a", 1, "users")
+	c.SetWithTags("b", 2, "users", "admins")
+	c.SetWithTags("c", 3, "admins")
+	c.Set("d", 4)
+
+	if n := c.InvalidateTag("users"); n != 2 {
+		t.Fatalf("InvalidateTag(users) = %d, want 2", n)
+	}
+	if c.Has("a") || c.Has("b") || !c.Has("c") || !c.Has("d") {
+		t.Errorf("Keys() = %v, want c and d", c.Keys())
+	}
+	if n := c.InvalidateTag("admins"); n != 1 {
+		t.Errorf("InvalidateTag(admins) = %d, want 1", n)
+	}
+}
+
+func TestTagsFollowEviction(t *testing.T) {
+	c := newCache[string, int](t, 1, time.Minute)
+	c.SetWithTags("a", 1, "t")
+	c.Set("b", 2) // evicts a
+
+	if n := c.InvalidateTag("t"); n != 0 {
+		t.Errorf("InvalidateTag(t) = %d after its only key was evicted, want 0", n)
+	}
+	if len(c.tags) != 0 {
+		t.Errorf("tag index %v still holds evicted keys", c.tags)
+	}
+}
+
+func TestTagsKeptWhenValueGrows(t *testing.T) {
+	c, err := NewWithBytes[string, string](8, time.Minute, func(v string) int64 { return int64(len(v)) })
+	if err != nil {
+		t.Fatalf("NewWithBytes: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetWithTags("a", "aa", "t")
+	c.Set("b", "bbbb")
+	c.Set("a", "aaaaaa") // has to evict b to fit
+
+	if c.Has("b") {
+		t.Fatal("b survived; want it evicted to make room")
+	}
+	if n := c.InvalidateTag("t"); n != 1 {
+		t.Errorf("InvalidateTag(t) = %d after a grew, want 1", n)
+	}
+}
+
+func TestInvalidateTagSkipsExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	c.SetWithTags("a", 1, "t")
+	clock.Advance(2 * time.Minute)
+	c.SetWithTags("b", 2, "t")
+
+	if n := c.InvalidateTag("t"); n != 1 {
+		t.Errorf("InvalidateTag(t) = %d with one of two entries expired, want 1", n)
+	}
+	if st := c.Stats(); st.Expirations != 1 {
+		t.Errorf("%d expirations, want the expired entry counted", st.Expirations)
+	}
+}
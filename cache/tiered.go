@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Backend is a slower store, such as Redis or disk, behind a Tiered cache.
+type Backend[K comparable, V any] interface {
+	Get(key K) (V, bool, error)
+	Set(key K, value V) error
+}
+
+// Tiered uses a Cache as an in-memory first level in front of a Backend.
+type Tiered[K comparable, V any] struct {
+	l1 *Cache[K, V]
+	l2 Backend[K, V]
+	wb *writeBehind[K, V] // nil when writing through
+}
+
+// writeBehind holds the writes a Tiered cache has yet to pass to its backend.
+type writeBehind[K comparable, V any] struct {
+	mu      sync.Mutex
+	pending map[K]queuedWrite[V] // latest value per key, so repeated writes coalesce
+	seq     uint64
+	depth   int
+	room    *sync.Cond // signalled when pending shrinks or closed is set
+	closed  bool
+	err     error // from the last flush, reported by Close
+
+	interval time.Duration
+	flush    chan struct{} // asks run to flush before the interval is up
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// queuedWrite is a value waiting to be written. seq tells a flush whether
+// the key was written again while its value was being passed on.
+type queuedWrite[V any] struct {
+	value V
+	seq   uint64
+}
+
+func NewTiered[K comparable, V any](l1 *Cache[K, V], l2 Backend[K, V]) *Tiered[K, V] {
+	return &Tiered[K, V]{l1: l1, l2: l2}
+}
+
+// NewWriteBehind is like NewTiered but Set only queues the write for the
+// backend. A background worker passes queued writes on every interval, or as
+// soon as depth distinct keys are waiting; writes to a key still waiting
+// replace the queued value. At most depth keys are queued: Set blocks while
+// the queue is full. A write the backend fails stays queued and is retried
+// on the next interval, until which a full queue does not trigger flushes.
+// Close must be called to flush what is left.
+func NewWriteBehind[K comparable, V any](l1 *Cache[K, V], l2 Backend[K, V], depth int, interval time.Duration) (*Tiered[K, V], error) {
+
+	if depth <= 0 {
+		return nil, fmt.Errorf("depth should be greater than zero")
+	}
+
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval should be greater than zero")
+	}
+
+	t := NewTiered(l1, l2)
+	t.wb = &writeBehind[K, V]{
+		pending:  make(map[K]queuedWrite[V]),
+		depth:    depth,
+		interval: interval,
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	t.wb.room = sync.NewCond(&t.wb.mu)
+	go t.run()
+	return t, nil
+}
+
+// Get returns the value for key from the first level, falling back to the
+// backend on a miss and promoting what it finds into the first level. A
+// backend error is returned along with ok == false.
+func (t *Tiered[K, V]) Get(key K) (V, bool, error) {
+	if value, ok := t.l1.Get(key); ok {
+		return value, true, nil
+	}
+	if value, ok := t.queued(key); ok {
+		return value, true, nil
+	}
+
+	value, ok, err := t.l2.Get(key)
+	if err != nil || !ok {
+		var zero V
+		return zero, false, err
+	}
+	t.l1.Set(key, value)
+	return value, true, nil
+}
+
+// Set writes value to both levels and returns the backend's error, if any.
+// In write-behind mode the backend write is queued, waiting for room if need
+// be, and Set returns nil. After Close it writes through.
+func (t *Tiered[K, V]) Set(key K, value V) error {
+	t.l1.Set(key, value)
+	if t.wb == nil {
+		return t.l2.Set(key, value)
+	}
+
+	wb := t.wb
+	wb.mu.Lock()
+	for !wb.closed && len(wb.pending) >= wb.depth {
+		if _, ok := wb.pending[key]; ok {
+			break // replacing a queued value takes no room
+		}
+		wb.room.Wait()
+	}
+	if wb.closed {
+		wb.mu.Unlock()
+		<-wb.done // the final flush may still be writing an older value
+		wb.mu.Lock()
+		delete(wb.pending, key) // left by a failed final flush
+		wb.mu.Unlock()
+		return t.l2.Set(key, value)
+	}
+	wb.seq++
+	wb.pending[key] = queuedWrite[V]{value, wb.seq}
+	full := len(wb.pending) >= wb.depth
+	wb.mu.Unlock()
+
+	if full {
+		select {
+		case wb.flush <- struct{}{}:
+		default: // a flush is already due
+		}
+	}
+	return nil
+}
+
+// Close flushes any queued writes and stops the write-behind worker,
+// returning the backend errors of that final flush. It does not close the
+// first level. Close is a no-op for a cache made by NewTiered.
+func (t *Tiered[K, V]) Close() error {
+	if t.wb == nil {
+		return nil
+	}
+
+	wb := t.wb
+	wb.mu.Lock()
+	if wb.closed {
+		wb.mu.Unlock()
+		<-wb.done
+		return nil
+	}
+	wb.closed = true
+	wb.room.Broadcast()
+	wb.mu.Unlock()
+
+	close(wb.stop)
+	<-wb.done
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.err
+}
+
+// queued returns the value waiting to be written for key, if any.
+func (t *Tiered[K, V]) queued(key K) (V, bool) {
+	if t.wb == nil {
+		var zero V
+		return zero, false
+	}
+	t.wb.mu.Lock()
+	defer t.wb.mu.Unlock()
+	w, ok := t.wb.pending[key]
+	return w.value, ok
+}
+
+// run flushes the queue every interval, or when asked unless the last flush
+// failed, until Close.
+func (t *Tiered[K, V]) run() {
+	defer close(t.wb.done)
+
+	timer := t.l1.clock.NewTimer(t.wb.interval)
+	failing := false
+	for {
+		select {
+		case <-timer.C():
+			failing = t.flushQueued() != nil
+			timer = t.l1.clock.NewTimer(t.wb.interval)
+		case <-t.wb.flush:
+			if !failing { // wait for the interval to retry
+				failing = t.flushQueued() != nil
+			}
+		case <-t.wb.stop:
+			timer.Stop()
+			t.flushQueued()
+			return
+		}
+	}
+}
+
+// flushQueued writes the queued values to the backend without holding the
+// queue lock and returns the backend's errors. Each key stays queued, and so
+// visible to Get, until its write succeeds, and stays queued after that if it
+// was written again meanwhile.
+func (t *Tiered[K, V]) flushQueued() error {
+	wb := t.wb
+	wb.mu.Lock()
+	batch := maps.Clone(wb.pending)
+	wb.mu.Unlock()
+
+	var errs []error
+	for k, w := range batch {
+		if err := t.l2.Set(k, w.value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		wb.mu.Lock()
+		if wb.pending[k].seq == w.seq {
+			delete(wb.pending, k)
+			wb.room.Broadcast()
+		}
+		wb.mu.Unlock()
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.err = errors.Join(errs...)
+	return wb.err
+}
@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBackend is an in-memory Backend whose calls can be made to fail.
+type memBackend struct {
+	mu     sync.Mutex
+	data   map[string]int
+	gets   int
+	sets   int // attempted, including failed ones
+	getErr error
+	setErr error
+	onSet  func(key string, value int) // if set, runs before each Set takes effect
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{data: make(map[string]int)}
+}
+
+func (b *memBackend) Get(key string) (int, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.gets++
+	if b.getErr != nil {
+		return 0, false, b.getErr
+	}
+	v, ok := b.data[key]
+	return v, ok, nil
+}
+
+func (b *memBackend) Set(key string, value int) error {
+	if b.onSet != nil {
+		b.onSet(key, value)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sets++
+	if b.setErr != nil {
+		return b.setErr
+	}
+	b.data[key] = value
+	return nil
+}
+
+func (b *memBackend) value(key string) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.data[key]
+	return v, ok
+}
+
+func (b *memBackend) attempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sets
+}
+
+func (b *memBackend) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setErr = err
+}
+
+func TestTieredPromotes(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	l2.data["a"] = 1
+	tc := NewTiered(l1, l2)
+
+	for range 2 {
+		if v, ok, err := tc.Get("a"); err != nil || !ok || v != 1 {
+			t.Fatalf("Get(a) = %v, %v, %v; want 1, true, nil", v, ok, err)
+		}
+	}
+	if l2.gets != 1 {
+		t.Errorf("backend read %d times, want once before promotion", l2.gets)
+	}
+	if _, ok, err := tc.Get("b"); ok || err != nil {
+		t.Errorf("Get(b) = %v, %v; want a miss without error", ok, err)
+	}
+}
+
+func TestTieredWritesThrough(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	tc := NewTiered(l1, l2)
+
+	if err := tc.Set("a", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if v, ok := l1.Peek("a"); !ok || v != 1 {
+		t.Errorf("first level holds %v, %v; want 1, true", v, ok)
+	}
+	if v, ok := l2.value("a"); !ok || v != 1 {
+		t.Errorf("backend holds %v, %v; want 1, true", v, ok)
+	}
+
+	l2.setErr = errors.New("backend down")
+	if err := tc.Set("b", 2); !errors.Is(err, l2.setErr) {
+		t.Errorf("Set with a failing backend = %v, want %v", err, l2.setErr)
+	}
+}
+
+func TestTieredBackendError(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	tc := NewTiered(l1, l2)
+	l1.Set("hit", 1)
+	l2.getErr = errors.New("backend down")
+
+	if _, ok, err := tc.Get("miss"); ok || !errors.Is(err, l2.getErr) {
+		t.Errorf("Get(miss) = %v, %v; want false, %v", ok, err, l2.getErr)
+	}
+	if v, ok, err := tc.Get("hit"); err != nil || !ok || v != 1 {
+		t.Errorf("Get(hit) = %v, %v, %v; want 1, true, nil", v, ok, err)
+	}
+}
+
+func newWriteBehind(t *testing.T, l1 *Cache[string, int], l2 Backend[string, int], depth int) *Tiered[string, int] {
+	t.Helper()
+	tc, err := NewWriteBehind(l1, l2, depth, time.Second)
+	if err != nil {
+		t.Fatalf("NewWriteBehind: %v", err)
+	}
+	t.Cleanup(func() { tc.Close() })
+	return tc
+}
+
+func TestWriteBehindInterval(t *testing.T) {
+	clock := newFakeClock()
+	l1 := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	l2 := newMemBackend()
+	tc := newWriteBehind(t, l1, l2, 100)
+
+	tc.Set("a", 1)
+	if _, ok := l2.value("a"); ok {
+		t.Fatal("write reached the backend before the interval")
+	}
+	eventually(t, func() bool { return clock.waiting() == 1 }, "worker is not waiting")
+	clock.Advance(time.Second)
+	eventually(t, func() bool { _, ok := l2.value("a"); return ok }, "write was not flushed after the interval")
+}
+
+func TestWriteBehindDepth(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	tc := newWriteBehind(t, l1, l2, 2)
+
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	eventually(t, func() bool { _, ok := l2.value("b"); return ok }, "full queue was not flushed")
+}
+
+func TestWriteBehindVisibleWhileFlushing(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	writing, release := make(chan struct{}), make(chan struct{})
+	l2.onSet = func(string, int) {
+		writing <- struct{}{}
+		<-release
+	}
+	tc := newWriteBehind(t, l1, l2, 1)
+
+	tc.Set("a", 1)
+	<-writing
+	l1.Delete("a")
+	if v, ok, err := tc.Get("a"); err != nil || !ok || v != 1 {
+		t.Errorf("Get(a) during its flush = %v, %v, %v; want 1, true, nil", v, ok, err)
+	}
+	close(release)
+	eventually(t, func() bool { _, ok := l2.value("a"); return ok }, "write was not flushed")
+}
+
+func TestWriteBehindRetries(t *testing.T) {
+	clock := newFakeClock()
+	l1 := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	l2 := newMemBackend()
+	l2.fail(errors.New("backend down"))
+	tc, err := NewWriteBehind(l1, l2, 100, time.Second)
+	if err != nil {
+		t.Fatalf("NewWriteBehind: %v", err)
+	}
+
+	tc.Set("a", 1)
+	for i := 1; i <= 2; i++ {
+		eventually(t, func() bool { return clock.waiting() == 1 }, "flush %d: worker is not waiting", i)
+		clock.Advance(time.Second)
+		eventually(t, func() bool { return l2.attempts() == i }, "flush %d did not try the backend", i)
+		if i == 1 {
+			l2.fail(nil)
+		}
+	}
+	if v, ok := l2.value("a"); !ok || v != 1 {
+		t.Errorf("backend holds %v, %v after the retry; want 1, true", v, ok)
+	}
+	if err := tc.Close(); err != nil {
+		t.Errorf("Close after a successful retry = %v, want nil", err)
+	}
+}
+
+func TestWriteBehindCloseDrains(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	tc, err := NewWriteBehind(l1, l2, 100, time.Hour)
+	if err != nil {
+		t.Fatalf("NewWriteBehind: %v", err)
+	}
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+
+	if err := tc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, ok := l2.value(k); !ok {
+			t.Errorf("Close did not flush %s", k)
+		}
+	}
+	if err := tc.Set("c", 3); err != nil {
+		t.Errorf("Set after Close: %v", err)
+	}
+	if _, ok := l2.value("c"); !ok {
+		t.Error("Set after Close did not write through")
+	}
+}
+
+func TestWriteBehindCloseError(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	l2.fail(errors.New("backend down"))
+	tc, err := NewWriteBehind(l1, l2, 100, time.Hour)
+	if err != nil {
+		t.Fatalf("NewWriteBehind: %v", err)
+	}
+	tc.Set("a", 1)
+
+	if err := tc.Close(); !errors.Is(err, l2.setErr) {
+		t.Errorf("Close = %v, want %v", err, l2.setErr)
+	}
+}
+
+func TestWriteBehindBounded(t *testing.T) {
+	clock := newFakeClock()
+	l1 := newCache[string, int](t, 10, time.Minute, WithClock(clock), WithLazyExpiry())
+	l2 := newMemBackend()
+	l2.fail(errors.New("backend down"))
+	tc := newWriteBehind(t, l1, l2, 2)
+
+	tc.Set("a", 1)
+	tc.Set("b", 2) // fills the queue and triggers a flush, which fails
+	eventually(t, func() bool { return l2.attempts() == 2 }, "full queue was not flushed")
+	for i := range 5 {
+		tc.Set("a", i) // replaces the queued value, asking for flushes that must wait
+	}
+	queued := make(chan struct{})
+	go func() {
+		tc.Set("c", 3)
+		close(queued)
+	}()
+
+	eventually(t, func() bool { return clock.waiting() == 1 }, "worker is not waiting")
+	clock.Advance(time.Second)
+	eventually(t, func() bool { return l2.attempts() == 4 }, "interval did not retry the queue")
+	select {
+	case <-queued:
+		t.Fatal("Set queued a third key while two were waiting")
+	default:
+	}
+	if n := l2.attempts(); n != 4 {
+		t.Errorf("%d backend writes, want 4 with retries held to the interval", n)
+	}
+
+	l2.fail(nil)
+	eventually(t, func() bool { return clock.waiting() == 1 }, "worker is not waiting")
+	clock.Advance(time.Second)
+	<-queued
+	if v, ok := l2.value("a"); !ok || v != 4 {
+		t.Errorf("backend holds a = %v, %v; want the latest 4", v, ok)
+	}
+}
+
+func TestWriteBehindSetAfterClose(t *testing.T) {
+	l1 := newCache[string, int](t, 10, time.Minute)
+	l2 := newMemBackend()
+	writing, release := make(chan struct{}), make(chan struct{})
+	l2.onSet = func(_ string, value int) {
+		if value == 1 {
+			writing <- struct{}{}
+			<-release
+		}
+	}
+	tc, err := NewWriteBehind(l1, l2, 100, time.Hour)
+	if err != nil {
+		t.Fatalf("NewWriteBehind: %v", err)
+	}
+
+	tc.Set("a", 1)
+	closed := make(chan error)
+	go func() { closed <- tc.Close() }()
+	<-writing // the final flush is writing a = 1
+	set := make(chan error)
+	go func() { set <- tc.Set("a", 2) }()
+	close(release)
+
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := <-set; err != nil {
+		t.Fatalf("Set after Close: %v", err)
+	}
+	if v, _ := l2.value("a"); v != 2 {
+		t.Errorf("backend holds a = %d, want the later write 2", v)
+	}
+}
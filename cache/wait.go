@@ -0,0 +1,56 @@
+package cache
+
+import "context"
+
+// waiter is shared by all goroutines waiting on one key; ch is closed when
+// the key is stored.
+type waiter struct {
+	ch chan struct{}
+	n  int
+}
+
+// Wait returns the value for key, blocking until the key is stored if it is
+// not present yet. It returns false if ctx is done first. All goroutines
+// waiting on a key are woken when it is stored.
+func (c *Cache[K, V]) Wait(ctx context.Context, key K) (V, bool) {
+	for {
+		c.mu.Lock()
+		if e, ok := c.live(key); ok {
+			c.policy.RecordAccess(key)
+			c.counters.hits.Add(1)
+			c.mu.Unlock()
+			return c.valueOf(e), true
+		}
+		if c.waiters == nil {
+			c.waiters = make(map[K]*waiter)
+		}
+		w, ok := c.waiters[key]
+		if !ok {
+			w = &waiter{ch: make(chan struct{})}
+			c.waiters[key] = w
+		}
+		w.n++
+		c.mu.Unlock()
+
+		select {
+		case <-w.ch:
+			// stored, but it may be gone again by the time we look
+		case <-ctx.Done():
+			c.mu.Lock()
+			if w.n--; w.n == 0 && c.waiters[key] == w {
+				delete(c.waiters, key)
+			}
+			c.mu.Unlock()
+			var zero V
+			return zero, false
+		}
+	}
+}
+
+// wakeWaiters releases everyone waiting on key. c.mu must be held.
+func (c *Cache[K, V]) wakeWaiters(key K) {
+	if w, ok := c.waiters[key]; ok {
+		close(w.ch)
+		delete(c.waiters, key)
+	}
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waiting returns how many goroutines are blocked in Wait on key.
+func (c *Cache[K, V]) waiting(key K) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if w, ok := c.waiters[key]; ok {
+		return w.n
+	}
+	return 0
+}
+
+func TestWaitWokenBySet(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	const waiters = 3
+	got := make(chan int, waiters)
+	for range waiters {
+		go func() {
+			v, ok := c.Wait(context.Background(), "k")
+			if !ok {
+				v = -1
+			}
+			got <- v
+		}()
+	}
+	eventually(t, func() bool { return c.waiting("k") == waiters }, "waiters did not block")
+
+	c.Set("k", 7)
+	for range waiters {
+		if v := <-got; v != 7 {
+			t.Errorf("Wait returned %d, want 7", v)
+		}
+	}
+	if v, ok := c.Wait(context.Background(), "k"); !ok || v != 7 {
+		t.Errorf("Wait on a present key = %v, %v; want 7, true", v, ok)
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	c := newCache[string, int](t, 10, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		_, ok := c.Wait(ctx, "k")
+		done <- ok
+	}()
+	eventually(t, func() bool { return c.waiting("k") == 1 }, "waiter did not block")
+
+	cancel()
+	if <-done {
+		t.Error("Wait = true after its context was cancelled")
+	}
+	if n := c.waiting("k"); n != 0 {
+		t.Errorf("%d waiters left registered after cancelling", n)
+	}
+}
@@ -17,7 +17,7 @@ func main() {
 	size := 5
 	ttl := 10 * time.Millisecond
 	log.Printf("info: creating cache: size=%d, ttl=%v", size, ttl)
-	c, err := cache.New(size, ttl)
+	c, err := cache.New[string, int](size, ttl)
 	if err != nil {
 		log.Printf("error: can't create - %s", err)
 		return
@@ -0,0 +1,68 @@
+// Package promcache exposes cache statistics as Prometheus metrics. It is a
+// separate module so that only users who import it depend on Prometheus.
+package promcache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/saikrir/cache/cache"
+)
+
+// Source is implemented by cache.Cache and cache.Sharded.
+type Source interface {
+	Stats() cache.Stats
+	Len() int
+	Cap() int
+}
+
+type collector struct {
+	src Source
+
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	evictions   *prometheus.Desc
+	expirations *prometheus.Desc
+	entries     *prometheus.Desc
+	capacity    *prometheus.Desc
+}
+
+// NewCollector returns a collector reporting the statistics of src, labelled
+// with cache=name so several caches can share a registry.
+func NewCollector(name string, src Source) prometheus.Collector {
+	labels := prometheus.Labels{"cache": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("cache", "", metric), help, nil, labels)
+	}
+	return &collector{
+		src:         src,
+		hits:        desc("hits_total", "Number of lookups that found a live entry."),
+		misses:      desc("misses_total", "Number of lookups that found no live entry."),
+		evictions:   desc("evictions_total", "Number of entries evicted to make room."),
+		expirations: desc("expirations_total", "Number of entries removed after their TTL."),
+		entries:     desc("entries", "Number of live entries."),
+		capacity:    desc("capacity", "Maximum number of entries, zero when bounded by bytes."),
+	}
+}
+
+// Register registers a collector for src with reg.
+func Register(reg prometheus.Registerer, name string, src Source) error {
+	return reg.Register(NewCollector(name, src))
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+	ch <- c.entries
+	ch <- c.capacity
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.src.Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(stats.Expirations))
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(c.src.Len()))
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(c.src.Cap()))
+}
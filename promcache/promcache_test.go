@@ -0,0 +1,55 @@
+package promcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/saikrir/cache/cache"
+)
+
+func TestRegister(t *testing.T) {
+	c, err := cache.New[string, int](10, time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("b")
+
+	reg := prometheus.NewRegistry()
+	if err := Register(reg, "test", c); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	got := make(map[string]float64)
+	for _, f := range families {
+		m := f.GetMetric()[0]
+		if l := m.GetLabel(); len(l) != 1 || l[0].GetValue() != "test" {
+			t.Errorf("%s has labels %v, want cache=test", f.GetName(), l)
+		}
+		if counter := m.GetCounter(); counter != nil {
+			got[f.GetName()] = counter.GetValue()
+		} else {
+			got[f.GetName()] = m.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"cache_hits_total":        1,
+		"cache_misses_total":      1,
+		"cache_evictions_total":   0,
+		"cache_expirations_total": 0,
+		"cache_entries":           1,
+		"cache_capacity":          10,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}